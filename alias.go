@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const aliasFileName = "aliases.json"
+
+var aliasNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+func aliasFile() string {
+	return filepath.Join(paths.Home, aliasFileName)
+}
+
+// loadAliases reads the alias name -> version tag mapping
+func loadAliases() (map[string]string, error) {
+	aliases := make(map[string]string)
+	data, err := os.ReadFile(aliasFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return aliases, nil
+		}
+		return nil, fmt.Errorf("failed to read aliases: %w", err)
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", aliasFile(), err)
+	}
+	return aliases, nil
+}
+
+func saveAliases(aliases map[string]string) error {
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(aliasFile(), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	return nil
+}
+
+// updateAliases applies update to the aliases under the aliases lock and
+// saves them when update reports a change
+func updateAliases(ctx context.Context, update func(aliases map[string]string) (bool, error)) error {
+	lock, err := lockAliases(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	aliases, err := loadAliases()
+	if err != nil {
+		return err
+	}
+	changed, err := update(aliases)
+	if err != nil || !changed {
+		return err
+	}
+	return saveAliases(aliases)
+}
+
+// validateAliasName rejects names that could be mistaken for a version query
+func validateAliasName(name string) error {
+	if !aliasNameRe.MatchString(name) {
+		return NewError(fmt.Sprintf("invalid alias %q, use letters, digits, '-', '_' or '.' starting with a letter", name))
+	}
+	switch strings.ToLower(name) {
+	case queryLatest, queryStable, queryOldStable:
+		return NewError(fmt.Sprintf("%q is a reserved version keyword", name))
+	}
+	if _, ok := parseGoVersion(name); ok {
+		return NewError(fmt.Sprintf("alias %q looks like a version", name))
+	}
+	return nil
+}
+
+// expandAlias returns the version an alias points to, or query unchanged
+func expandAlias(query string) string {
+	aliases, err := loadAliases()
+	if err != nil {
+		Warnf("%v", err)
+		return query
+	}
+	if tag, ok := aliases[query]; ok {
+		return tag
+	}
+	return query
+}
+
+// aliasesByTag groups alias names by the version they point to
+func aliasesByTag() map[string][]string {
+	aliases, err := loadAliases()
+	if err != nil {
+		Warnf("%v", err)
+		return nil
+	}
+	byTag := make(map[string][]string)
+	for name, tag := range aliases {
+		byTag[tag] = append(byTag[tag], name)
+	}
+	for tag := range byTag {
+		sort.Strings(byTag[tag])
+	}
+	return byTag
+}
+
+// removeAliasesFor drops every alias pointing to tag and returns their names
+func removeAliasesFor(ctx context.Context, tag string) ([]string, error) {
+	var removed []string
+	err := updateAliases(ctx, func(aliases map[string]string) (bool, error) {
+		for name, t := range aliases {
+			if t == tag {
+				delete(aliases, name)
+				removed = append(removed, name)
+			}
+		}
+		return len(removed) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
+	"time"
 )
 
-const (
-	goDevAPI    = "https://go.dev/dl/?mode=json"
-	goDevAPIAll = "https://go.dev/dl/?mode=json&include=all"
-	goDevDL     = "https://go.dev/dl/"
-)
+// goDevDL is the official download site, the default mirror and the default
+// source of the release index
+const goDevDL = "https://go.dev/dl/"
 
 // GoRelease represents a Go version release from the official API
 type GoRelease struct {
@@ -31,28 +36,158 @@ type GoFile struct {
 	Kind     string `json:"kind"` // "source", "archive", "installer"
 }
 
-// FetchReleases fetches Go releases from the official API
+// StatusError is returned when a server answers with an unexpected status
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned status %d for %s", e.StatusCode, e.URL)
+}
+
+// shouldFailover reports whether err means the mirror itself is unavailable,
+// a connection error, a 5xx answer or an unreadable local directory, so the
+// next mirror is worth a try
+func shouldFailover(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	var pathErr *fs.PathError
+	return errors.As(err, &urlErr) || errors.As(err, &pathErr)
+}
+
+// localMirrorPath returns the filesystem path of a mirror or archive URL
+// given as a plain path or a file:// URL, such as a directory built by
+// sv mirror sync
+func localMirrorPath(s string) (string, bool) {
+	if rest, ok := strings.CutPrefix(s, "file://"); ok {
+		return filepath.FromSlash(rest), true
+	}
+	if strings.Contains(s, "://") {
+		return "", false
+	}
+	return s, true
+}
+
+// FetchReleases returns the Go release index. It only ever comes from
+// cfg.Index, never from the download mirrors, because its checksums are what
+// every archive is verified against. A cached copy younger than
+// cfg.IndexTTL is used as is, otherwise the index is revalidated with a
+// conditional request. In offline mode only a local index directory and the
+// cache are consulted.
 func FetchReleases(client *http.Client, includeAll bool) ([]GoRelease, error) {
-	url := goDevAPI
+	if cfg.Offline {
+		if dir, ok := localMirrorPath(cfg.Index); ok {
+			if releases, err := readMirrorIndex(dir, includeAll); err == nil {
+				return releases, nil
+			}
+		}
+		return cachedReleases(includeAll)
+	}
+
+	cached, meta := loadIndexCache(includeAll)
+	if cached != nil && time.Since(meta.FetchedAt) < cfg.IndexTTL {
+		return cached, nil
+	}
+
+	releases, err := fetchReleasesFrom(client, cfg.Index, includeAll, cached, meta)
+	if err == nil {
+		return releases, nil
+	}
+	if cached != nil {
+		Warnf("Using release index cached at %s: %v", meta.FetchedAt.Local().Format(time.DateTime), err)
+		return cached, nil
+	}
+	return nil, fmt.Errorf("failed to fetch releases: %w", err)
+}
+
+func fetchReleasesFrom(client *http.Client, source string, includeAll bool, cached []GoRelease, meta *indexMeta) ([]GoRelease, error) {
+	if dir, ok := localMirrorPath(source); ok {
+		return readMirrorIndex(dir, includeAll)
+	}
+
+	indexURL := source + "?mode=json"
 	if includeAll {
-		url = goDevAPIAll
+		indexURL += "&include=all"
+	}
+	releases, err := fetchIndex(client, indexURL, includeAll, cached, meta)
+
+	// A static file server hosting a synced directory ignores ?mode=json and
+	// only has the full index.json
+	var syntaxErr *json.SyntaxError
+	var statusErr *StatusError
+	if errors.As(err, &syntaxErr) || errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		cachedAll, metaAll := loadIndexCache(true)
+		if releases, err = fetchIndex(client, source+mirrorIndexFile, true, cachedAll, metaAll); err == nil && !includeAll {
+			releases = stableReleases(releases)
+			// refresh the current-releases cache too, or SV_INDEX_TTL never
+			// applies to it and every lookup goes back to the server
+			if body, err := json.Marshal(releases); err == nil {
+				meta := &indexMeta{URL: source + mirrorIndexFile, FetchedAt: time.Now()}
+				if err := saveIndexCache(false, body, meta); err != nil {
+					Warnf("Failed to cache release index: %v", err)
+				}
+			}
+		}
 	}
+	return releases, err
+}
 
-	resp, err := client.Get(url)
+// fetchIndex downloads the index at indexURL, revalidating the cached copy
+// for includeAll when it came from the same URL
+func fetchIndex(client *http.Client, indexURL string, includeAll bool, cached []GoRelease, meta *indexMeta) ([]GoRelease, error) {
+	req, err := http.NewRequest(http.MethodGet, indexURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch releases: %w", err)
+		return nil, err
+	}
+	// Validators only apply to the copy fetched from this very URL
+	if cached != nil && meta.URL == indexURL {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		meta.FetchedAt = time.Now()
+		if err := saveIndexCache(includeAll, nil, meta); err != nil {
+			Warnf("Failed to update release index cache: %v", err)
+		}
+		return cached, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode, URL: indexURL}
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read releases: %w", err)
+	}
 	var releases []GoRelease
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+	if err := json.Unmarshal(body, &releases); err != nil {
 		return nil, fmt.Errorf("failed to parse releases: %w", err)
 	}
 
+	newMeta := &indexMeta{
+		URL:          indexURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}
+	if err := saveIndexCache(includeAll, body, newMeta); err != nil {
+		Warnf("Failed to cache release index: %v", err)
+	}
 	return releases, nil
 }
 
@@ -67,17 +202,29 @@ func (r *GoRelease) FindMatchingFile() *GoFile {
 	return nil
 }
 
-// DownloadURL returns the full download URL for a file
+// DownloadURL returns the full download URL for a file on the first mirror
 func (f *GoFile) DownloadURL() string {
-	return goDevDL + f.Filename
+	return f.DownloadURLs()[0]
+}
+
+// DownloadURLs returns the download URL for a file on every mirror, in order
+func (f *GoFile) DownloadURLs() []string {
+	urls := make([]string, len(cfg.Mirrors))
+	for i, mirror := range cfg.Mirrors {
+		urls[i] = mirror + f.Filename
+	}
+	return urls
 }
 
-// ToPackage converts a GoFile to a Package for compatibility
+// ToPackage converts a GoFile to a Package for compatibility. The checksum
+// always comes from the trusted index, whichever mirror serves the archive.
 func (f *GoFile) ToPackage(version string) *Package {
+	urls := f.DownloadURLs()
 	return &Package{
 		Name:      f.Filename,
 		Tag:       version,
-		URL:       f.DownloadURL(),
+		URL:       urls[0],
+		Mirrors:   urls[1:],
 		Kind:      f.Kind,
 		OS:        f.OS,
 		Arch:      f.Arch,
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -9,6 +11,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/urfave/cli/v2"
@@ -22,17 +25,20 @@ type app struct {
 func newApp(ctx *cli.Context) *app {
 	return &app{
 		ctx:    ctx,
-		client: &http.Client{Timeout: cfg.HTTPTimeout},
+		client: newHTTPClient(cfg.HTTPTimeout),
 	}
 }
 
 func (a *app) Run() error {
 	// Handle subcommands by checking lineage
 	cmdName := a.ctx.Command.Name
-	// Check if this is a subcommand under "self" by looking at Lineage
-	if len(a.ctx.Lineage()) > 2 {
-		// Lineage: [current context, self context, app context]
-		cmdName = "self " + cmdName
+	// Check if this is a subcommand under "self" by looking at Lineage.
+	// Lineage: [current context, parent context, ..., app context]
+	if lineage := a.ctx.Lineage(); len(lineage) > 1 && lineage[1].Command != nil {
+		switch parent := lineage[1].Command.Name; parent {
+		case "self", "alias", "mirror":
+			cmdName = parent + " " + cmdName
+		}
 	}
 
 	switch cmdName {
@@ -42,6 +48,34 @@ func (a *app) Run() error {
 		return a.handleUse()
 	case "install":
 		return a.handleInstall()
+	case "local":
+		return a.handleLocal()
+	case "exec":
+		return a.handleExec()
+	case "shell":
+		return a.handleShell()
+	case "env":
+		return a.handleEnv()
+	case "mirror serve":
+		return a.handleMirrorServe()
+	case "mirror sync":
+		return a.handleMirrorSync()
+	case "hook":
+		return a.handleHook()
+	case "hook-env":
+		return a.handleHookEnv()
+	case "alias":
+		// "sv alias <name> <version>" is shorthand for "sv alias set"
+		if a.ctx.NArg() == 2 {
+			return a.handleAliasSet()
+		}
+		return a.handleAliasList()
+	case "alias ls":
+		return a.handleAliasList()
+	case "alias set":
+		return a.handleAliasSet()
+	case "alias rm":
+		return a.handleAliasRemove()
 	case "uninstall":
 		return a.handleUninstall()
 	case "prune":
@@ -71,46 +105,138 @@ func (a *app) handleList() error {
 }
 
 func (a *app) handleUse() error {
-	target := a.ctx.Args().First()
-	if target == "" {
-		return ErrTagEmpty()
+	query, fromProject, err := a.targetQuery()
+	if err != nil {
+		return err
+	}
+	tag, err := a.resolveUse(query)
+	if err != nil {
+		return err
 	}
 
-	tag := normalizeVersionTag(target)
 	p := &Package{
 		Tag:  tag,
 		Name: generateFileName(tag),
 	}
 
-	if err := p.useLocal(); err != nil {
-		return a.promptRemoteInstall(tag)
+	// a failed switch to an installed version is not fixed by downloading it
+	if inCache(tag) {
+		return p.useLocal(a.ctx.Context)
 	}
-	return nil
+	if err := p.useLocal(a.ctx.Context); err == nil {
+		return nil
+	}
+
+	// A version pinned by the project is installed without asking
+	if fromProject {
+		remote, err := a.findRemotePackage(tag)
+		if err != nil {
+			return err
+		}
+		return remote.use(a.ctx.Context)
+	}
+	return a.promptRemoteInstall(tag)
+}
+
+// targetQuery returns the version given on the command line, falling back to
+// the version pinned by go.work, go.mod or .go-version in the working directory
+func (a *app) targetQuery() (query string, fromProject bool, err error) {
+	if target := a.ctx.Args().First(); target != "" {
+		return target, false, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", false, err
+	}
+	pv, err := findProjectVersion(wd)
+	if err != nil {
+		return "", false, err
+	}
+	PrintCyan(fmt.Sprintf("Using %s from %s", pv.Tag, pv.Source))
+	return pv.Tag, true, nil
+}
+
+// resolveInstalled resolves a version query against the installed versions
+func (a *app) resolveInstalled(query string) (string, error) {
+	return resolveLocal(query)
+}
+
+// resolveLocal resolves a version query against the installed versions
+// without touching the network, fast enough for shims and shell hooks
+func resolveLocal(query string) (string, error) {
+	query = expandAlias(query)
+	q, err := parseVersionQuery(query)
+	if err != nil {
+		return "", err
+	}
+	if tag, ok := q.exactTag(); ok {
+		return tag, nil
+	}
+
+	versions, err := (&Package{}).getLocalVersion()
+	if err != nil {
+		return "", err
+	}
+	return q.resolve(localReleases(versions))
+}
+
+// resolveAvailable resolves a version query against the release index,
+// fetching it when releases is nil
+func (a *app) resolveAvailable(query string, releases []GoRelease) (string, error) {
+	query = expandAlias(query)
+	q, err := parseVersionQuery(query)
+	if err != nil {
+		return "", err
+	}
+	if tag, ok := q.exactTag(); ok {
+		return tag, nil
+	}
+
+	if releases == nil {
+		if releases, err = FetchReleases(a.client, true); err != nil {
+			return "", err
+		}
+	}
+	return q.resolve(releases)
+}
+
+// resolveUse prefers an installed version matching the query and falls back
+// to the release index. Keywords such as latest always consult the index.
+func (a *app) resolveUse(query string) (string, error) {
+	query = expandAlias(query)
+	q, err := parseVersionQuery(query)
+	if err != nil {
+		return "", err
+	}
+	if !q.isKeyword() {
+		if tag, err := a.resolveInstalled(query); err == nil {
+			return tag, nil
+		}
+	}
+	return a.resolveAvailable(query, nil)
 }
 
 func (a *app) handleInstall() error {
+	if src := a.ctx.String("from-file"); src != "" {
+		return a.installFromFile(src, strings.ToLower(a.ctx.String("sha256")))
+	}
+
 	releases, err := FetchReleases(a.client, true)
 	if err != nil {
 		return err
 	}
 
-	var tag string
-	if a.ctx.Bool("latest") {
-		for _, r := range releases {
-			if r.Stable {
-				tag = r.Version
-				break
-			}
-		}
-		if tag == "" {
-			return NewError("no stable version found")
+	query := queryStable
+	if !a.ctx.Bool("latest") {
+		if query, _, err = a.targetQuery(); err != nil {
+			return err
 		}
-	} else {
-		target := a.ctx.Args().First()
-		if target == "" {
-			return ErrTagEmpty()
-		}
-		tag = normalizeVersionTag(target)
+	}
+
+	tag, err := a.resolveAvailable(query, releases)
+	if err != nil {
+		return err
 	}
 
 	release := FindRelease(releases, tag)
@@ -123,7 +249,83 @@ func (a *app) handleInstall() error {
 		return NewError(fmt.Sprintf("no package found for %s/%s", runtime.GOOS, runtime.GOARCH))
 	}
 
-	return file.ToPackage(release.Version).install()
+	return file.ToPackage(release.Version).install(a.ctx.Context)
+}
+
+// installFromFile installs an official archive copied onto the machine. The
+// version comes from the file name or the VERSION file inside the archive,
+// and the checksum from --sha256 or the cached release index. No network
+// access happens.
+func (a *app) installFromFile(src, checksum string) error {
+	if !Exists(src) {
+		return NewError("archive not found: " + src)
+	}
+	ext := archiveExt(src)
+	if ext == "" {
+		return NewError("unsupported archive format: " + src)
+	}
+
+	base := filepath.Base(src)
+	tag, goos, goarch, _, ok := parseArchiveName(base)
+	if ok {
+		if goos != runtime.GOOS || goarch != runtime.GOARCH {
+			return NewError(fmt.Sprintf("%s is built for %s/%s, not %s/%s", base, goos, goarch, runtime.GOOS, runtime.GOARCH))
+		}
+	} else {
+		v, err := archiveVersion(src)
+		if err != nil {
+			return fmt.Errorf("cannot tell the version of %s: %w", base, err)
+		}
+		tag = normalizeVersionTag(v)
+		if ext == ".tgz" {
+			ext = ".tar.gz"
+		}
+		base = fmt.Sprintf("%s.%s-%s%s", tag, runtime.GOOS, runtime.GOARCH, ext)
+		PrintYellow(fmt.Sprintf("Archive name is not official, assuming %s", base))
+	}
+
+	if checksum == "" {
+		if releases, err := cachedReleases(true); err == nil {
+			if release := FindRelease(releases, tag); release != nil {
+				for _, f := range release.Files {
+					if f.Filename == base {
+						checksum = f.SHA256
+					}
+				}
+			}
+		}
+	}
+
+	computed, err := fileSHA256(src)
+	if err != nil {
+		return err
+	}
+	if checksum == "" {
+		PrintYellow("No checksum given and none in the cached release index, installing unverified archive")
+	} else if checksum != computed {
+		return ErrChecksumMismatch()
+	} else {
+		PrintGreen("Checksum verified")
+	}
+
+	lock, err := lockVersion(a.ctx.Context, tag)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	dst := filepath.Join(paths.Download, base)
+	if !sameFile(src, dst) {
+		if err := copyFile(src, dst); err != nil {
+			return fmt.Errorf("failed to copy archive: %w", err)
+		}
+	}
+	if err := writeChecksumFile(dst, computed); err != nil {
+		return err
+	}
+
+	p := &Package{Tag: tag, Name: base, Checksum: computed, Algorithm: "SHA256", verified: true}
+	return p.useDownloaded(a.ctx.Context)
 }
 
 func (a *app) handleUninstall() error {
@@ -132,17 +334,473 @@ func (a *app) handleUninstall() error {
 		return ErrTagEmpty()
 	}
 
-	tag := normalizeVersionTag(target)
+	tag, err := a.resolveInstalled(target)
+	if err != nil {
+		return err
+	}
 	p := &Package{
 		Tag:  tag,
 		Name: generateFileName(tag),
 	}
-	return p.remove()
+	if err := p.remove(a.ctx.Context); err != nil {
+		return err
+	}
+
+	removed, err := removeAliasesFor(a.ctx.Context, tag)
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		PrintCyan(fmt.Sprintf("Removed alias(es) %s", strings.Join(removed, ", ")))
+	}
+	return nil
+}
+
+func (a *app) handleLocal() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(wd, goVersionFile)
+
+	if a.ctx.Bool("unset") {
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				return NewInfo("no " + goVersionFile + " in the current directory")
+			}
+			return err
+		}
+		PrintGreen("Removed " + path)
+		return nil
+	}
+
+	target := a.ctx.Args().First()
+	if target == "" {
+		found := findLocalVersionFile(wd)
+		if found == "" {
+			return NewInfo("no local version set, run: sv local <version>")
+		}
+		tag, err := parseGoVersionFile(found)
+		if err != nil {
+			return err
+		}
+		fmt.Println(tag)
+		return nil
+	}
+
+	// Written without the "go" prefix so other version managers can read it too
+	tag, err := a.resolveUse(target)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(strings.TrimPrefix(tag, "go")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	PrintGreen(fmt.Sprintf("Set %s for %s", tag, wd))
+
+	if err := installShims(); err != nil {
+		return err
+	}
+	if !inCache(tag) {
+		PrintYellow(fmt.Sprintf("%s is not installed yet, run: sv install %s", tag, tag))
+	}
+	if shimsShadowed() {
+		PrintYellow(fmt.Sprintf("%s comes before %s in PATH, put %s first so the shims can pick the version",
+			filepath.Join(paths.Root, "bin"), paths.Bin, paths.Bin))
+	}
+	return nil
+}
+
+func (a *app) handleExec() error {
+	args := a.ctx.Args().Slice()
+	if len(args) == 0 {
+		return ErrTagEmpty()
+	}
+
+	tag, err := a.resolveUse(args[0])
+	if err != nil {
+		return err
+	}
+	args = args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return NewError("no command given, usage: sv exec <version> -- <command>")
+	}
+
+	if err := a.ensureInstalled(tag); err != nil {
+		return err
+	}
+
+	goroot := filepath.Join(paths.Cache, tag)
+	name, err := lookPathIn(filepath.Join(goroot, "bin"), args[0])
+	if err != nil {
+		return err
+	}
+
+	code, err := runCommand(name, args[1:], goEnv(goroot))
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return cli.Exit("", code)
+	}
+	return nil
+}
+
+func (a *app) handleShell() error {
+	shell := a.ctx.String("shell")
+	if shell == "" {
+		shell = detectShell()
+	}
+
+	var tag string
+	if !a.ctx.Bool("unset") {
+		target := a.ctx.Args().First()
+		if target == "" {
+			return evalError(NewError("usage: sv shell <version> or sv shell --unset"))
+		}
+		var err error
+		if tag, err = a.resolveInstalled(target); err != nil {
+			return evalError(err)
+		}
+		if !inCache(tag) {
+			return evalError(NewError(fmt.Sprintf("version %s is not installed, run: sv install %s", tag, tag)))
+		}
+	}
+
+	out, err := formatEnv(shell, sessionEnv(tag))
+	if err != nil {
+		return evalError(err)
+	}
+	fmt.Print(out)
+
+	// Printing alone changes nothing, remind the user how to apply it
+	if isTerminal(os.Stdout) {
+		cmdline := "sv shell --unset"
+		if tag != "" {
+			cmdline = "sv shell " + a.ctx.Args().First()
+		}
+		switch shell {
+		case "fish":
+			fmt.Fprintf(os.Stderr, "# apply with: %s | source\n", cmdline)
+		case "powershell", "pwsh":
+			fmt.Fprintf(os.Stderr, "# apply with: %s | Invoke-Expression\n", cmdline)
+		default:
+			fmt.Fprintf(os.Stderr, "# apply with: eval \"$(%s)\"\n", cmdline)
+		}
+	}
+	return nil
+}
+
+func (a *app) handleEnv() error {
+	if a.ctx.Bool("write") {
+		written, err := writeEnvFiles()
+		if err != nil {
+			return err
+		}
+		for _, path := range written {
+			PrintGreen("Wrote " + path)
+		}
+		return nil
+	}
+
+	shell := a.ctx.String("shell")
+	if shell == "" {
+		shell = detectShell()
+	}
+
+	vars := globalEnv()
+	if target := a.ctx.Args().First(); target != "" {
+		tag, err := a.resolveInstalled(target)
+		if err != nil {
+			return evalError(err)
+		}
+		if !inCache(tag) {
+			return evalError(NewError(fmt.Sprintf("version %s is not installed, run: sv install %s", tag, tag)))
+		}
+		vars = sessionEnv(tag)
+	}
+
+	out, err := formatEnv(shell, append(vars, configEnv()...))
+	if err != nil {
+		return evalError(err)
+	}
+	fmt.Print(out)
+	return nil
+}
+
+func (a *app) handleMirrorServe() error {
+	addr := a.ctx.String("addr")
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return NewError(fmt.Sprintf("invalid --addr %q, expected [host]:port such as :8080", addr))
+	}
+
+	dir := a.ctx.String("dir")
+	if dir == "" {
+		dir = paths.Download
+	}
+	m := newMirrorServer(dir)
+
+	// Checksum everything up front so the first client does not wait
+	releases, err := m.index()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, r := range releases {
+		count += len(r.Files)
+	}
+
+	PrintGreen(fmt.Sprintf("Serving %d archive(s) of %d version(s) from %s on %s", count, len(releases), dir, addr))
+	PrintCyan(fmt.Sprintf("On other machines: export SV_MIRRORS=http://<this-host>:%s/", port))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           m,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-a.ctx.Context.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return a.ctx.Context.Err()
+}
+
+func (a *app) handleMirrorSync() error {
+	dir := a.ctx.String("dir")
+	platforms, err := parsePlatforms(a.ctx.String("platform"))
+	if err != nil {
+		return err
+	}
+	q, err := parseVersionQuery(a.ctx.String("versions"))
+	if err != nil {
+		return err
+	}
+
+	all, err := FetchReleases(a.client, true)
+	if err != nil {
+		return err
+	}
+	var releases []GoRelease
+	if q.isKeyword() {
+		tag, err := q.resolve(all)
+		if err != nil {
+			return err
+		}
+		releases = append(releases, *FindRelease(all, tag))
+	} else {
+		for _, r := range all {
+			if q.matches(r.Version) {
+				releases = append(releases, r)
+			}
+		}
+		if len(releases) == 0 {
+			return ErrNoVersionMatches(a.ctx.String("versions"))
+		}
+	}
+
+	names := make([]string, len(platforms))
+	for i, p := range platforms {
+		names[i] = p[0] + "/" + p[1]
+	}
+	PrintCyan(fmt.Sprintf("Syncing %d version(s) for %s into %s", len(releases), strings.Join(names, ", "), dir))
+
+	fetched, kept, err := syncMirror(a.ctx.Context, dir, releases, platforms)
+	if err != nil {
+		return err
+	}
+	PrintGreen(fmt.Sprintf("Mirror is up to date: %d downloaded, %d already present", fetched, kept))
+	return nil
+}
+
+func (a *app) handleHook() error {
+	shell := a.ctx.Args().First()
+	if shell == "" {
+		shell = detectShell()
+	}
+
+	var flags []string
+	for _, f := range []string{"install", "global"} {
+		if a.ctx.Bool(f) {
+			flags = append(flags, f)
+		}
+	}
+
+	script, err := hookScript(shell, flags)
+	if err != nil {
+		return evalError(err)
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// handleHookEnv runs from the shell hook on every prompt or cd, so the common
+// path only reads local files and never touches the network
+func (a *app) handleHookEnv() error {
+	// Everything but the final exports goes to stderr, the shell evals stdout
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+
+	shell := a.ctx.String("shell")
+	if shell == "" {
+		shell = detectShell()
+	}
+
+	tag, source := hookTarget()
+	hookSource := os.Getenv(envHookSource)
+
+	var vars []envVar
+	switch {
+	case tag == "":
+		// Left the project, undo the hook's switch but keep a manual sv shell
+		if hookSource == "" {
+			return nil
+		}
+		vars = append(sessionEnv(""), envVar{Name: envHookSource})
+	case a.ctx.Bool("global"):
+		if tag == getGlobalVersion() {
+			return nil
+		}
+		installed, ok := a.hookInstalled(tag, source, shell, stdout)
+		if !ok {
+			return nil
+		}
+		p := &Package{Tag: installed, Name: generateFileName(installed)}
+		return p.useCached(a.ctx.Context)
+	default:
+		if os.Getenv(envGoVersion) != "" && hookSource == "" {
+			return nil
+		}
+		if tag == sessionVersion() {
+			return nil
+		}
+		installed, ok := a.hookInstalled(tag, source, shell, stdout)
+		if !ok {
+			return nil
+		}
+		vars = append(sessionEnv(installed), envVar{Name: envHookSource, Value: source})
+	}
+
+	out, err := formatEnv(shell, vars)
+	if err != nil {
+		return evalError(err)
+	}
+	fmt.Fprint(stdout, out)
+	return nil
+}
+
+// hookInstalled returns the installed version tag resolves to, installing
+// the newest match when the hook was set up with --install. A missing version
+// is reported once per version file.
+func (a *app) hookInstalled(tag, source, shell string, stdout *os.File) (string, bool) {
+	if inCache(tag) {
+		return tag, true
+	}
+	if a.ctx.Bool("install") {
+		resolved, err := a.resolveAvailable(tag, nil)
+		if err == nil {
+			err = a.ensureInstalled(resolved)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sv: failed to install %s: %v\n", tag, err)
+			return "", false
+		}
+		return resolved, true
+	}
+
+	if os.Getenv(envHookWarned) != source {
+		fmt.Fprintf(os.Stderr, "sv: %s (set by %s) is not installed, run: sv install %s\n", tag, source, tag)
+		if out, err := formatEnv(shell, []envVar{{Name: envHookWarned, Value: source}}); err == nil {
+			fmt.Fprint(stdout, out)
+		}
+	}
+	return "", false
+}
+
+func (a *app) handleAliasList() error {
+	aliases, err := loadAliases()
+	if err != nil {
+		return err
+	}
+	if len(aliases) == 0 {
+		return NewInfo("no aliases defined, run: sv alias set <name> <version>")
+	}
+
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tag := aliases[name]
+		if inCache(tag) {
+			fmt.Printf("%s -> %s\n", name, tag)
+		} else {
+			PrintYellow(fmt.Sprintf("%s -> %s (not installed)", name, tag))
+		}
+	}
+	return nil
+}
+
+func (a *app) handleAliasSet() error {
+	name, target := a.ctx.Args().Get(0), a.ctx.Args().Get(1)
+	if name == "" || target == "" {
+		return NewError("usage: sv alias set <name> <version>")
+	}
+	if err := validateAliasName(name); err != nil {
+		return err
+	}
+
+	tag, err := a.resolveInstalled(target)
+	if err != nil {
+		return err
+	}
+	if !inCache(tag) {
+		return NewError(fmt.Sprintf("version %s is not installed, run: sv install %s", tag, tag))
+	}
+
+	err = updateAliases(a.ctx.Context, func(aliases map[string]string) (bool, error) {
+		aliases[name] = tag
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	PrintGreen(fmt.Sprintf("%s -> %s", name, tag))
+	return nil
+}
+
+func (a *app) handleAliasRemove() error {
+	name := a.ctx.Args().First()
+	if name == "" {
+		return NewError("usage: sv alias rm <name>")
+	}
+
+	err := updateAliases(a.ctx.Context, func(aliases map[string]string) (bool, error) {
+		if _, ok := aliases[name]; !ok {
+			return false, NewInfo(fmt.Sprintf("alias %s does not exist", name))
+		}
+		delete(aliases, name)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	PrintGreen("Removed alias " + name)
+	return nil
 }
 
 func (a *app) handleUpgrade() error {
 	u := NewUpgrade(a.ctx.Bool("force"))
-	return u.checkUpgrade()
+	return u.checkUpgrade(a.ctx.Context)
 }
 
 func (a *app) promptRemoteInstall(tag string) error {
@@ -158,22 +816,64 @@ func (a *app) promptRemoteInstall(tag string) error {
 		return nil
 	}
 
-	releases, err := FetchReleases(a.client, true)
+	p, err := a.findRemotePackage(tag)
+	if err != nil {
+		return err
+	}
+	return p.useRemote(a.ctx.Context)
+}
+
+// ensureInstalled makes tag available under paths.Cache without switching to
+// it, downloading the archive when it is not present locally
+func (a *app) ensureInstalled(tag string) error {
+	if inCache(tag) {
+		return nil
+	}
+
+	lock, err := lockVersion(a.ctx.Context, tag)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	// another sv process may have installed it while we waited
+	if inCache(tag) {
+		return nil
+	}
+
+	p := &Package{Tag: tag, Name: generateFileName(tag)}
+	if inDownload(p.Name) {
+		return p.unpack(a.ctx.Context)
+	}
+
+	remote, err := a.findRemotePackage(tag)
 	if err != nil {
 		return err
 	}
+	PrintCyan(fmt.Sprintf("%s is not installed, installing...", tag))
+	if err := remote.download(a.ctx.Context); err != nil {
+		return err
+	}
+	return remote.unpack(a.ctx.Context)
+}
+
+// findRemotePackage looks up the archive of tag for the current platform
+func (a *app) findRemotePackage(tag string) (*Package, error) {
+	releases, err := FetchReleases(a.client, true)
+	if err != nil {
+		return nil, err
+	}
 
 	release := FindRelease(releases, tag)
 	if release == nil {
-		return NewError("version not found: " + tag)
+		return nil, NewError("version not found: " + tag)
 	}
 
 	file := release.FindMatchingFile()
 	if file == nil {
-		return NewError(fmt.Sprintf("no package found for %s/%s", runtime.GOOS, runtime.GOARCH))
+		return nil, NewError(fmt.Sprintf("no package found for %s/%s", runtime.GOOS, runtime.GOARCH))
 	}
 
-	return file.ToPackage(release.Version).useRemote()
+	return file.ToPackage(release.Version), nil
 }
 
 func (a *app) listRemote() error {
@@ -202,7 +902,7 @@ func (a *app) listRemote() error {
 		return NewError(fmt.Sprintf("no package found for %s/%s", runtime.GOOS, runtime.GOARCH))
 	}
 
-	return file.ToPackage(release.Version).use()
+	return file.ToPackage(release.Version).use(a.ctx.Context)
 }
 
 func (a *app) listLocal() error {
@@ -219,7 +919,7 @@ func (a *app) listLocal() error {
 
 	pkg.Tag = target
 	pkg.Name = generateFileName(target)
-	return pkg.useLocal()
+	return pkg.useLocal(a.ctx.Context)
 }
 
 func (a *app) selectVersions(versions []string) (string, error) {
@@ -231,18 +931,27 @@ func (a *app) selectVersions(versions []string) (string, error) {
 		return versionCompare(versions[i]) > versionCompare(versions[j])
 	})
 
+	byTag := aliasesByTag()
+	options := make([]string, len(versions))
+	for i, v := range versions {
+		options[i] = v
+		if names := byTag[v]; len(names) > 0 {
+			options[i] += " [" + strings.Join(names, ", ") + "]"
+		}
+	}
+
 	var target string
 	err := survey.AskOne(&survey.Select{
 		Message: "Choose a version:",
 		Help:    "Enter to install the selected version",
-		Options: versions,
+		Options: options,
 	}, &target, survey.WithValidator(survey.Required), surveyIcon())
 	if err != nil {
 		return "", err
 	}
 
-	// Remove any suffix like " (date)"
-	if i := strings.Index(target, "("); i != -1 {
+	// Remove any suffix like " (date)" or " [alias]"
+	if i := strings.IndexAny(target, "(["); i != -1 {
 		target = strings.TrimSpace(target[:i])
 	}
 
@@ -271,6 +980,7 @@ func (a *app) handlePrune() error {
 	})
 
 	currentVersion := getCurrentVersion()
+	globalVersion := getGlobalVersion()
 	keep := a.ctx.Int("keep")
 	if keep < 1 {
 		keep = 2
@@ -278,9 +988,11 @@ func (a *app) handlePrune() error {
 
 	var toRemove, toKeep []string
 	kept := 0
+	byTag := aliasesByTag()
 
 	for _, v := range versions {
-		isCurrent := v == currentVersion
+		// Versions behind an alias are pinned just like the active one
+		isCurrent := v == currentVersion || v == globalVersion || len(byTag[v]) > 0
 
 		if a.ctx.Bool("all") {
 			if isCurrent {
@@ -334,7 +1046,7 @@ func (a *app) handlePrune() error {
 	removed := 0
 	for _, v := range toRemove {
 		p := &Package{Tag: v, Name: generateFileName(v)}
-		if err := p.removeLocal(); err != nil {
+		if err := p.removeLocal(a.ctx.Context); err != nil {
 			Warnf("Failed to remove %s: %v", v, err)
 			continue
 		}
@@ -347,11 +1059,15 @@ func (a *app) handlePrune() error {
 }
 
 func (a *app) handleCurrent() error {
-	current := getCurrentVersion()
+	current, source := currentVersion()
 	if current == "" {
 		return NewInfo("no Go version is currently active")
 	}
-	fmt.Println(current)
+	if source == paths.Root {
+		fmt.Println(current)
+	} else {
+		fmt.Printf("%s (set by %s)\n", current, source)
+	}
 	return nil
 }
 
@@ -365,7 +1081,10 @@ func (a *app) handleWhere() error {
 		target = current
 	}
 
-	tag := normalizeVersionTag(target)
+	tag, err := a.resolveInstalled(target)
+	if err != nil {
+		return err
+	}
 	versionPath := filepath.Join(paths.Cache, tag)
 
 	if !Exists(versionPath) {
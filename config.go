@@ -1,23 +1,60 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	UpgradeAPIURL string
-	HTTPTimeout   time.Duration
-	DownloadRetry int
-	Debug         bool
+	UpgradeAPIURL       string
+	HTTPTimeout         time.Duration
+	DownloadRetry       int
+	DownloadConcurrency int   // connections a single download opens at most
+	LimitRate           int64 // total download bandwidth in bytes per second, 0 for no limit
+	Debug               bool
+	Env                 map[string]string     // extra variables emitted by sv env
+	Mirrors             []string              // download sites tried in order, each ending in "/"
+	Index               string                // trusted source of the release index and its checksums, ending in "/"
+	IndexTTL            time.Duration         // how long a cached release index is used without revalidation
+	Offline             bool                  // use only the cached index and local archives
+	Hosts               map[string]hostConfig // credentials and headers per mirror host
+	CAFile              string                // extra CA bundle trusted for HTTPS
+	Proxy               string                // proxy URL, overriding HTTPS_PROXY and friends
+	LockTimeout         time.Duration         // how long to wait for another sv process, 0 waits forever
+	ExtractMaxSize      int64                 // total bytes an archive may expand to, 0 for no limit
+	ExtractMaxFiles     int                   // entries an archive may hold, 0 for no limit
+	ExtractMaxFileSize  int64                 // bytes a single extracted file may hold, 0 for no limit
+}
+
+// fileConfig is the optional ~/.sv/config.json, overridden by SV_* variables
+type fileConfig struct {
+	Env     map[string]string     `json:"env"`
+	Mirrors []string              `json:"mirrors"`
+	Index   string                `json:"index"`
+	Hosts   map[string]hostConfig `json:"hosts"`
+	CAFile  string                `json:"ca_file"`
+	Proxy   string                `json:"proxy"`
 }
 
 var defaultConfig = &Config{
-	UpgradeAPIURL: "https://api.github.com/repos/voocel/sv/releases/latest",
-	HTTPTimeout:   30 * time.Second,
-	DownloadRetry: 3,
-	Debug:         false,
+	UpgradeAPIURL:       "https://api.github.com/repos/voocel/sv/releases/latest",
+	HTTPTimeout:         30 * time.Second,
+	DownloadRetry:       3,
+	DownloadConcurrency: 4,
+	Debug:               false,
+	Mirrors:             []string{goDevDL},
+	Index:               goDevDL,
+	IndexTTL:            time.Hour,
+	Offline:             false,
+	LockTimeout:         10 * time.Minute,
+	ExtractMaxSize:      1 << 30,
+	ExtractMaxFiles:     100000,
+	ExtractMaxFileSize:  256 << 20,
 }
 
 var cfg *Config
@@ -27,11 +64,41 @@ func init() {
 }
 
 func loadConfig() *Config {
+	fc, err := loadConfigFile()
+	if err != nil {
+		Warnf("%v", err)
+	}
+
 	config := &Config{
-		UpgradeAPIURL: getEnv("SV_UPGRADE_API_URL", defaultConfig.UpgradeAPIURL),
-		HTTPTimeout:   getEnvDuration("SV_HTTP_TIMEOUT", defaultConfig.HTTPTimeout),
-		DownloadRetry: getEnvInt("SV_DOWNLOAD_RETRY", defaultConfig.DownloadRetry),
-		Debug:         getEnvBool("SV_DEBUG", defaultConfig.Debug),
+		UpgradeAPIURL:       getEnv("SV_UPGRADE_API_URL", defaultConfig.UpgradeAPIURL),
+		HTTPTimeout:         getEnvDuration("SV_HTTP_TIMEOUT", defaultConfig.HTTPTimeout),
+		DownloadRetry:       getEnvInt("SV_DOWNLOAD_RETRY", defaultConfig.DownloadRetry),
+		DownloadConcurrency: getEnvInt("SV_DOWNLOAD_CONCURRENCY", defaultConfig.DownloadConcurrency),
+		LimitRate:           getEnvSize("SV_LIMIT_RATE", defaultConfig.LimitRate),
+		Debug:               getEnvBool("SV_DEBUG", defaultConfig.Debug),
+		Env:                 fc.Env,
+		Mirrors:             getEnvList("SV_MIRRORS", fc.Mirrors, defaultConfig.Mirrors),
+		Index:               getEnv("SV_INDEX", fc.Index),
+		IndexTTL:            getEnvDuration("SV_INDEX_TTL", defaultConfig.IndexTTL),
+		Offline:             getEnvBool("SV_OFFLINE", defaultConfig.Offline),
+		Hosts:               fc.Hosts,
+		CAFile:              getEnv("SV_CA_FILE", fc.CAFile),
+		Proxy:               getEnv("SV_PROXY", fc.Proxy),
+		LockTimeout:         getEnvDuration("SV_LOCK_TIMEOUT", defaultConfig.LockTimeout),
+		ExtractMaxSize:      getEnvSize("SV_EXTRACT_MAX_SIZE", defaultConfig.ExtractMaxSize),
+		ExtractMaxFiles:     getEnvInt("SV_EXTRACT_MAX_FILES", defaultConfig.ExtractMaxFiles),
+		ExtractMaxFileSize:  getEnvSize("SV_EXTRACT_MAX_FILE_SIZE", defaultConfig.ExtractMaxFileSize),
+	}
+	for i, mirror := range config.Mirrors {
+		if !strings.HasSuffix(mirror, "/") {
+			config.Mirrors[i] = mirror + "/"
+		}
+	}
+	if config.Index == "" {
+		config.Index = defaultConfig.Index
+	}
+	if !strings.HasSuffix(config.Index, "/") {
+		config.Index += "/"
 	}
 
 	if config.Debug {
@@ -41,6 +108,38 @@ func loadConfig() *Config {
 	return config
 }
 
+// configFilePath returns SV_CONFIG or ~/.sv/config.json
+func configFilePath() string {
+	if path := os.Getenv("SV_CONFIG"); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".sv", "config.json")
+}
+
+func loadConfigFile() (*fileConfig, error) {
+	fc := &fileConfig{}
+	path := configFilePath()
+	if path == "" {
+		return fc, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fc, nil
+		}
+		return fc, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, fc); err != nil {
+		return &fileConfig{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return fc, nil
+}
+
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -48,6 +147,25 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getEnvList reads a comma separated list from key, then from the config
+// file, then falls back to the default
+func getEnvList(key string, fromFile, fallback []string) []string {
+	var list []string
+	if value := os.Getenv(key); value != "" {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	} else {
+		list = append(list, fromFile...)
+	}
+	if len(list) == 0 {
+		return append([]string(nil), fallback...)
+	}
+	return list
+}
+
 func getEnvInt(key string, fallback int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -74,3 +192,40 @@ func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return fallback
 }
+
+func getEnvSize(key string, fallback int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if size, err := parseSize(value); err == nil {
+			return size
+		}
+	}
+	return fallback
+}
+
+// parseSize parses a byte count such as 500K, 5M or 1.5G. Units are
+// powers of 1024, like curl's --limit-rate, and may end in B or iB.
+func parseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	multiplier := 1.0
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional K, M or G suffix", s)
+	}
+	return int64(n * multiplier), nil
+}
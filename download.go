@@ -2,32 +2,58 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// chunkSize is the size of the ranges workers pull from the queue
+	chunkSize int64 = 4 << 20
+	// minSplitSize is the smallest half an idle worker takes over from a
+	// busy one, below that finishing the range is cheaper than a new request
+	minSplitSize int64 = 512 << 10
+	// stateSaveInterval is how often download progress is written to disk
+	stateSaveInterval = time.Second
+)
+
+// errRemoteChanged means the server ignored If-Range because the file
+// changed since the download started
+var errRemoteChanged = errors.New("remote file changed during download")
+
 type Downloader struct {
 	concurrency int
 	tag         string
+	dir         string // where the finished file is written
+	checksum    string // expected SHA256, checked as the file is written
 	bar         *Bar
 	client      *http.Client
 }
 
 func NewDownloader(concurrency int, tag string) *Downloader {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 	return &Downloader{
 		tag:         tag,
+		dir:         paths.Download,
 		concurrency: concurrency,
-		client:      &http.Client{},
+		client:      newHTTPClient(0),
 	}
 }
 
-func (d *Downloader) Download(strURL, filename string) error {
+func (d *Downloader) Download(ctx context.Context, strURL, filename string) error {
 	if strURL == "" {
 		return NewError("download URL is empty")
 	}
@@ -36,10 +62,23 @@ func (d *Downloader) Download(strURL, filename string) error {
 		filename = filepath.Base(strURL)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+	if src, ok := localMirrorPath(strURL); ok {
+		partial := d.partialPath(filename)
+		if err := copyFile(src, partial); err != nil {
+			return err
+		}
+		sum, err := fileSHA256(partial)
+		if err != nil {
+			os.Remove(partial)
+			return err
+		}
+		return d.commit(filename, sum)
+	}
+
+	headCtx, cancel := context.WithTimeout(ctx, cfg.HTTPTimeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, strURL, nil)
+	req, err := http.NewRequestWithContext(headCtx, http.MethodHead, strURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -51,183 +90,545 @@ func (d *Downloader) Download(strURL, filename string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status %d for %s", resp.StatusCode, strURL)
+		return &StatusError{StatusCode: resp.StatusCode, URL: strURL}
 	}
 
 	if resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength > 0 {
-		return d.multiDownload(strURL, filename, resp.ContentLength)
+		return d.multiDownload(ctx, strURL, filename, &downloadState{
+			URL:          strURL,
+			Size:         resp.ContentLength,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			ChunkSize:    chunkSize,
+		})
+	}
+
+	return d.singleDownload(ctx, strURL, filename)
+}
+
+// chunk is a byte range of the file written to its own part file. The worker
+// owning it advances next, and an idle worker may take over the tail of the
+// range by lowering end.
+type chunk struct {
+	start int64 // first byte, also names the part file
+
+	mu   sync.Mutex
+	next int64 // next byte to write
+	end  int64 // last byte, inclusive
+}
+
+func (c *chunk) remaining() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.end - c.next + 1
+}
+
+// chunkQueue hands chunks to workers. Once the queue is drained, idle
+// workers split the largest range still in progress, so a slow connection
+// only holds up the bytes it is actually downloading.
+type chunkQueue struct {
+	mu      sync.Mutex
+	pending []*chunk
+	active  map[*chunk]bool
+	all     []*chunk
+}
+
+func newChunkQueue(chunks []*chunk) *chunkQueue {
+	q := &chunkQueue{active: make(map[*chunk]bool), all: chunks}
+	for _, c := range chunks {
+		if c.remaining() > 0 {
+			q.pending = append(q.pending, c)
+		}
+	}
+	return q
+}
+
+// take returns the next chunk to download, or nil when nothing is left
+// that is worth another connection
+func (q *chunkQueue) take() *chunk {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) > 0 {
+		c := q.pending[0]
+		q.pending = q.pending[1:]
+		q.active[c] = true
+		return c
+	}
+
+	var victim *chunk
+	var most int64
+	for c := range q.active {
+		if r := c.remaining(); r > most {
+			victim, most = c, r
+		}
+	}
+	if victim == nil || most < 2*minSplitSize {
+		return nil
+	}
+
+	victim.mu.Lock()
+	mid := victim.next + (victim.end-victim.next+1)/2
+	stolen := &chunk{start: mid, next: mid, end: victim.end}
+	victim.end = mid - 1
+	victim.mu.Unlock()
+
+	q.all = append(q.all, stolen)
+	q.active[stolen] = true
+	return stolen
+}
+
+func (q *chunkQueue) done(c *chunk) {
+	q.mu.Lock()
+	delete(q.active, c)
+	q.mu.Unlock()
+}
+
+// chunks returns every chunk ordered by offset, ready to be merged
+func (q *chunkQueue) chunks() []*chunk {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	sorted := append([]*chunk(nil), q.all...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	return sorted
+}
+
+// snapshot returns the progress of every chunk, taken while no chunk can be
+// split so the ranges always cover the file
+func (q *chunkQueue) snapshot() []chunkState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	states := make([]chunkState, 0, len(q.all))
+	for _, c := range q.all {
+		c.mu.Lock()
+		states = append(states, chunkState{Start: c.start, Next: c.next, End: c.end})
+		c.mu.Unlock()
 	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Start < states[j].Start })
+	return states
+}
+
+// downloadState is saved next to the part files of an unfinished download.
+// A later attempt resumes from it only if the remote file is still the same.
+type downloadState struct {
+	URL          string       `json:"url"`
+	Size         int64        `json:"size"`
+	ETag         string       `json:"etag,omitempty"`
+	LastModified string       `json:"last_modified,omitempty"`
+	ChunkSize    int64        `json:"chunk_size"`
+	Chunks       []chunkState `json:"chunks"`
+}
 
-	return d.singleDownload(strURL, filename)
+// chunkState records a chunk range, bytes start to next-1 are done
+type chunkState struct {
+	Start int64 `json:"start"`
+	Next  int64 `json:"next"`
+	End   int64 `json:"end"`
 }
 
-func (d *Downloader) multiDownload(strURL, filename string, contentLen int64) error {
-	partSize := contentLen / int64(d.concurrency)
-	partDir := d.getPartDir(filename)
+// validator returns the value to send in If-Range. Weak ETags are not
+// allowed there, Last-Modified is used instead.
+func (s *downloadState) validator() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+	return s.LastModified
+}
+
+// matches reports whether a saved state describes the same remote file and
+// chunk layout as the current one
+func (s *downloadState) matches(remote *downloadState) bool {
+	return s.URL == remote.URL &&
+		s.Size == remote.Size &&
+		s.ETag == remote.ETag &&
+		s.LastModified == remote.LastModified &&
+		s.ChunkSize == remote.ChunkSize &&
+		remote.validator() != ""
+}
+
+// loadState returns the chunks of an earlier attempt at the same remote file,
+// or nil when there is nothing that can be trusted. Parts may be longer than
+// recorded, the extra bytes are dropped, but never shorter.
+func (d *Downloader) loadState(partDir string, remote *downloadState) []*chunk {
+	data, err := os.ReadFile(filepath.Join(partDir, "state.json"))
+	if err != nil {
+		return nil
+	}
+	var saved downloadState
+	if err := json.Unmarshal(data, &saved); err != nil || !saved.matches(remote) {
+		return nil
+	}
+
+	chunks := make([]*chunk, 0, len(saved.Chunks))
+	var offset int64
+	for _, cs := range saved.Chunks {
+		if cs.Start != offset || cs.Next < cs.Start || cs.Next > cs.End+1 {
+			return nil
+		}
+		info, err := os.Stat(d.getPartFilename(remote.URL, cs.Start))
+		if cs.Next > cs.Start && (err != nil || info.Size() < cs.Next-cs.Start) {
+			return nil
+		}
+		chunks = append(chunks, &chunk{start: cs.Start, next: cs.Next, end: cs.End})
+		offset = cs.End + 1
+	}
+	if offset != remote.Size {
+		return nil
+	}
+	return chunks
+}
+
+// saveState records the progress of every chunk
+func (d *Downloader) saveState(partDir string, state *downloadState, q *chunkQueue) error {
+	snapshot := *state
+	snapshot.Chunks = q.snapshot()
+	data, err := json.MarshalIndent(&snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(partDir, "state.json"), data)
+}
+
+// multiDownload fetches the chunks of the file in parallel. When it fails or
+// ctx is cancelled the parts and their state are kept for the next attempt.
+func (d *Downloader) multiDownload(ctx context.Context, strURL, filename string, remote *downloadState) error {
+	partDir := d.getPartDir(strURL)
+	chunks := d.loadState(partDir, remote)
+	if chunks == nil {
+		// nothing to resume from, start over with a clean directory
+		os.RemoveAll(partDir)
+		chunks = planChunks(remote.Size, remote.ChunkSize)
+	}
 	if err := os.MkdirAll(partDir, 0755); err != nil {
 		return err
 	}
 
 	var downloaded int64
-	for i := 0; i < d.concurrency; i++ {
-		if info, err := os.Stat(d.getPartFilename(filename, i)); err == nil {
-			downloaded += info.Size()
-		}
+	for _, c := range chunks {
+		downloaded += c.next - c.start
 	}
 
-	d.bar = NewBar(contentLen)
+	d.bar = NewBar(remote.Size)
 	d.bar.SetName("sv["+d.tag+"]", "pink")
+	d.bar.SetLimit(cfg.LimitRate)
 	if downloaded > 0 {
 		d.bar.Add(downloaded)
 	}
 
+	q := newChunkQueue(chunks)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	var (
-		wg    sync.WaitGroup
-		errCh = make(chan error, d.concurrency)
+		wg      sync.WaitGroup
+		errCh   = make(chan error, d.concurrency)
+		changed atomic.Bool
+		saved   = make(chan struct{})
 	)
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(stateSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.saveState(partDir, remote, q); err != nil {
+					Warnf("Failed to save download state: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	var rangeStart int64
 	for i := 0; i < d.concurrency; i++ {
 		wg.Add(1)
-		rangeEnd := rangeStart + partSize - 1
-		if i == d.concurrency-1 {
-			rangeEnd = contentLen - 1
-		}
-
-		go func(i int, start, end int64) {
+		go func() {
 			defer wg.Done()
-			if err := d.downloadPartial(strURL, filename, start, end, i); err != nil {
-				select {
-				case errCh <- fmt.Errorf("part %d: %w", i, err):
-				default:
+			for c := q.take(); c != nil; c = q.take() {
+				err := retryFunc(ctx, func() error {
+					if ctx.Err() != nil {
+						return nil
+					}
+					err := d.downloadChunk(ctx, strURL, remote.validator(), c)
+					if errors.Is(err, errRemoteChanged) {
+						// retrying cannot help, the saved parts are useless now
+						changed.Store(true)
+						cancel()
+						return nil
+					}
+					return err
+				}, cfg.DownloadRetry)
+				q.done(c)
+				if err != nil {
+					select {
+					case errCh <- fmt.Errorf("range %d-%d: %w", c.start, c.end, err):
+					default:
+					}
+					cancel()
+					return
+				}
+				if ctx.Err() != nil {
+					return
 				}
 			}
-		}(i, rangeStart, rangeEnd)
-
-		rangeStart = rangeEnd + 1
+		}()
 	}
 
 	wg.Wait()
+	cancel()
+	<-saved
 	close(errCh)
 
+	if changed.Load() {
+		d.bar.Stop()
+		os.RemoveAll(partDir)
+		return errRemoteChanged
+	}
 	if err, ok := <-errCh; ok {
+		d.bar.Stop()
+		if err := d.saveState(partDir, remote, q); err != nil {
+			Warnf("Failed to save download state: %v", err)
+		}
 		return err
 	}
 
-	if err := d.merge(filename); err != nil {
+	sum, err := d.merge(strURL, filename, q.chunks(), remote.Size)
+	if err != nil {
+		os.Remove(d.partialPath(filename))
 		return err
 	}
 
+	// the parts are done with either way, a corrupt file is fetched afresh
 	os.RemoveAll(partDir)
-	return nil
+	os.Remove(filepath.Dir(partDir)) // only succeeds once no other download is pending
+	return d.commit(filename, sum)
 }
 
-func (d *Downloader) singleDownload(strURL, filename string) error {
-	resp, err := d.client.Get(strURL)
+// partialPath is where filename is written until it has been verified, so
+// an interrupted download never looks like a complete archive
+func (d *Downloader) partialPath(filename string) string {
+	return filepath.Join(d.dir, filename+".partial")
+}
+
+// commit checks the SHA256 computed while writing the partial file against
+// the expected one, then moves it into place next to its checksum file. A
+// mismatch quarantines the file instead.
+func (d *Downloader) commit(filename, sum string) error {
+	partial := d.partialPath(filename)
+	if d.checksum != "" && !strings.EqualFold(d.checksum, sum) {
+		return checksumFailed(partial, filename, d.checksum, sum)
+	}
+	dst := filepath.Join(d.dir, filename)
+	if err := os.Rename(partial, dst); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return writeChecksumFile(dst, sum)
+}
+
+// planChunks splits a file of size bytes into ranges of chunkSize
+func planChunks(size, chunkSize int64) []*chunk {
+	var chunks []*chunk
+	for start := int64(0); start < size; start += chunkSize {
+		end := min(start+chunkSize, size) - 1
+		chunks = append(chunks, &chunk{start: start, next: start, end: end})
+	}
+	return chunks
+}
+
+// singleDownload streams the file in one request. A partial file is removed
+// on failure so it is never mistaken for a complete archive.
+func (d *Downloader) singleDownload(ctx context.Context, strURL, filename string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status %d for %s", resp.StatusCode, strURL)
+		return &StatusError{StatusCode: resp.StatusCode, URL: strURL}
 	}
 
 	d.bar = NewBar(resp.ContentLength)
 	d.bar.SetName("sv["+d.tag+"]", "pink")
+	d.bar.SetLimit(cfg.LimitRate)
 
-	f, err := os.OpenFile(filepath.Join(paths.Download, filename), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	partial := d.partialPath(filename)
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer f.Close()
 
+	h := sha256.New()
 	buf := make([]byte, 32*1024)
-	_, err = io.CopyBuffer(io.MultiWriter(f, d.bar), resp.Body, buf)
+	n, err := io.CopyBuffer(io.MultiWriter(f, h, d.bar), throttle(ctx, resp.Body), buf)
+	if err == nil && resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = fmt.Errorf("got %d of %d bytes", n, resp.ContentLength)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		d.bar.Stop()
+		os.Remove(partial)
 		return fmt.Errorf("failed to write file: %w", err)
 	}
-	return nil
+	return d.commit(filename, hex.EncodeToString(h.Sum(nil)))
 }
 
-func (d *Downloader) downloadPartial(strURL, filename string, rangeStart, rangeEnd int64, i int) error {
-	partFile := d.getPartFilename(filename, i)
-	expectedSize := rangeEnd - rangeStart + 1
-
-	var downloaded int64
-	if info, err := os.Stat(partFile); err == nil {
-		downloaded = info.Size()
-	}
-
-	if downloaded >= expectedSize {
+// downloadChunk fetches the rest of c into its part file. It stops as soon
+// as c is complete, even when another worker took over part of the range
+// after the request was sent. With a validator the request carries If-Range,
+// so a changed file is reported instead of mixed into the parts.
+func (d *Downloader) downloadChunk(ctx context.Context, strURL, validator string, c *chunk) error {
+	c.mu.Lock()
+	next, end := c.next, c.end
+	c.mu.Unlock()
+	if next > end {
 		return nil
 	}
 
-	req, err := http.NewRequest(http.MethodGet, strURL, nil)
+	f, err := os.OpenFile(d.getPartFilename(strURL, c.start), os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rangeStart+downloaded, rangeEnd))
+	defer f.Close()
+	// drop whatever a failed attempt wrote past the recorded progress
+	if err := f.Truncate(next - c.start); err != nil {
+		return err
+	}
+	if _, err := f.Seek(next-c.start, io.SeekStart); err != nil {
+		return err
+	}
 
-	// dynamically calculate the timeout period based on the remaining size
-	remaining := expectedSize - downloaded
-	timeout := time.Duration(remaining/1024/10+30) * time.Second
-	if timeout > 10*time.Minute {
+	// dynamically calculate the timeout period based on the remaining size,
+	// assuming at least 10KB/s or this worker's share of the rate limit
+	remaining := end - next + 1
+	speed := int64(10 * 1024)
+	if cfg.LimitRate > 0 {
+		speed = max(min(speed, cfg.LimitRate/int64(d.concurrency)), 1)
+	}
+	timeout := time.Duration(remaining/speed+30) * time.Second
+	if timeout > 10*time.Minute && cfg.LimitRate == 0 {
 		timeout = 10 * time.Minute
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	req = req.WithContext(ctx)
 
-	resp, err := d.client.Do(req)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strURL, nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", next, end))
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
 	}
 
-	f, err := os.OpenFile(partFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	resp, err := d.client.Do(req)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer resp.Body.Close()
 
+	// a 200 would restart from byte 0 and corrupt the part
+	if resp.StatusCode == http.StatusOK && validator != "" {
+		return errRemoteChanged
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return &StatusError{StatusCode: resp.StatusCode, URL: strURL}
+	}
+
+	body := throttle(ctx, resp.Body)
 	buf := make([]byte, 32*1024)
-	_, err = io.CopyBuffer(io.MultiWriter(f, d.bar), resp.Body, buf)
-	return err
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			c.mu.Lock()
+			if limit := c.end - c.next + 1; int64(n) > limit {
+				n = int(limit)
+			}
+			_, err := f.Write(buf[:n])
+			if err == nil {
+				c.next += int64(n)
+			}
+			complete := c.next > c.end
+			c.mu.Unlock()
+
+			if err != nil {
+				return err
+			}
+			d.bar.Add(int64(n))
+			if complete {
+				return nil
+			}
+		}
+		if readErr == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
 }
 
-func (d *Downloader) merge(filename string) error {
-	dstFile, err := os.OpenFile(filepath.Join(paths.Download, filename), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+// merge joins the parts in order into the partial file and returns the
+// SHA256 of the result, hashed on the way so the archive is not read a
+// second time
+func (d *Downloader) merge(strURL, filename string, chunks []*chunk, size int64) (string, error) {
+	dstFile, err := os.OpenFile(d.partialPath(filename), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer dstFile.Close()
 
-	for i := 0; i < d.concurrency; i++ {
-		partFileName := d.getPartFilename(filename, i)
-		partFile, err := os.Open(partFileName)
+	h := sha256.New()
+	w := io.MultiWriter(dstFile, h)
+	var offset int64
+	for _, c := range chunks {
+		if c.start != offset || c.next != c.end+1 {
+			return "", fmt.Errorf("range %d-%d is incomplete", c.start, c.end)
+		}
+		partFile, err := os.Open(d.getPartFilename(strURL, c.start))
 		if err != nil {
-			return fmt.Errorf("failed to open part %d: %w", i, err)
+			return "", fmt.Errorf("failed to open part at %d: %w", c.start, err)
 		}
-		_, err = io.Copy(dstFile, partFile)
+		_, err = io.CopyN(w, partFile, c.end-c.start+1)
 		partFile.Close()
 		if err != nil {
-			return fmt.Errorf("failed to merge part %d: %w", i, err)
+			return "", fmt.Errorf("failed to merge part at %d: %w", c.start, err)
 		}
+		offset = c.end + 1
+	}
+	if offset != size {
+		return "", fmt.Errorf("parts cover %d of %d bytes", offset, size)
 	}
 
-	return nil
+	if err := dstFile.Sync(); err != nil {
+		return "", err
+	}
+	if err := dstFile.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-func (d *Downloader) getPartDir(filename string) string {
-	return filepath.Join(paths.Download, strings.SplitN(filepath.Base(filename), ".", 2)[0])
+// getPartDir returns the directory holding the parts and state of a
+// download. It is keyed by URL, so archives of different versions or from
+// different mirrors never share parts.
+func (d *Downloader) getPartDir(strURL string) string {
+	sum := sha256.Sum256([]byte(strURL))
+	return filepath.Join(d.dir, ".parts", hex.EncodeToString(sum[:8]))
 }
 
-func (d *Downloader) getPartFilename(filename string, partNum int) string {
-	return filepath.Join(d.getPartDir(filename), fmt.Sprintf("%s-%d", filename, partNum))
+// getPartFilename names a part file by the offset its range starts at
+func (d *Downloader) getPartFilename(strURL string, start int64) string {
+	return filepath.Join(d.getPartDir(strURL), fmt.Sprintf("%d.part", start))
 }
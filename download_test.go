@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rangeServer serves data with range and If-Range support under etag
+func rangeServer(t *testing.T, data []byte, etag string, wrap func(http.ResponseWriter) http.ResponseWriter) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if wrap != nil {
+			w = wrap(w)
+		}
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func newTestDownloader(t *testing.T, concurrency int) *Downloader {
+	t.Helper()
+	home := t.TempDir()
+	paths = &Paths{Home: home, Download: filepath.Join(home, "downloads")}
+	d := NewDownloader(concurrency, "test")
+	d.dir = t.TempDir()
+	return d
+}
+
+// pausingWriter stops once, after limit bytes, until resume is closed
+type pausingWriter struct {
+	http.ResponseWriter
+	limit   int
+	written int
+	once    *sync.Once
+	paused  chan struct{}
+	resume  chan struct{}
+}
+
+func (w *pausingWriter) Write(p []byte) (int, error) {
+	if w.written >= w.limit {
+		w.once.Do(func() {
+			w.ResponseWriter.(http.Flusher).Flush()
+			close(w.paused)
+			<-w.resume
+		})
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += n
+	return n, err
+}
+
+func TestDownloadSplitChunk(t *testing.T) {
+	data := testData(2 << 20)
+	var once sync.Once
+	paused, resume := make(chan struct{}), make(chan struct{})
+	s := rangeServer(t, data, `"v1"`, func(w http.ResponseWriter) http.ResponseWriter {
+		return &pausingWriter{ResponseWriter: w, limit: 256 << 10, once: &once, paused: paused, resume: resume}
+	})
+	url := s.URL + "/go.tar.gz"
+
+	d := newTestDownloader(t, 2)
+	d.bar = NewBar(int64(len(data)))
+	defer d.bar.Stop()
+	if err := os.MkdirAll(d.getPartDir(url), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	q := newChunkQueue(planChunks(int64(len(data)), chunkSize))
+	first := q.take()
+	errs := make(chan error, 2)
+	go func() { errs <- d.downloadChunk(context.Background(), url, `"v1"`, first) }()
+
+	// split the chunk once its first bytes are on disk, so the range the
+	// first worker is reading runs past its new end
+	<-paused
+	deadline := time.Now().Add(5 * time.Second)
+	for first.remaining() == int64(len(data)) {
+		if time.Now().After(deadline) {
+			close(resume)
+			t.Fatal("no bytes arrived before the split")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stolen := q.take()
+	close(resume)
+	if stolen == nil {
+		t.Fatal("take did not split the chunk in progress")
+	}
+	if stolen.start <= first.start || first.end != stolen.start-1 {
+		t.Fatalf("split into %d-%d and %d-%d, want adjacent ranges", first.start, first.end, stolen.start, stolen.end)
+	}
+	go func() { errs <- d.downloadChunk(context.Background(), url, `"v1"`, stolen) }()
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatalf("downloadChunk: %v", err)
+		}
+	}
+
+	sum, err := d.merge(url, "go.tar.gz", q.chunks(), int64(len(data)))
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	got, err := os.ReadFile(d.partialPath("go.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("merged file differs from the served file")
+	}
+	if want, _ := fileSHA256(d.partialPath("go.tar.gz")); sum != want {
+		t.Errorf("merge returned sum %s, want %s", sum, want)
+	}
+}
+
+func TestDownloadRemoteChanged(t *testing.T) {
+	data := testData(1 << 20)
+	tests := []struct {
+		name    string
+		etag    string // ETag seen when the download started
+		changed bool
+	}{
+		{name: "same", etag: `"v2"`},
+		{name: "changed", etag: `"v1"`, changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := rangeServer(t, data, `"v2"`, nil).URL + "/go.tar.gz"
+			d := newTestDownloader(t, 2)
+
+			// a part left over from an earlier attempt at the old file
+			partDir := d.getPartDir(url)
+			if err := os.MkdirAll(partDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(d.getPartFilename(url, 0), []byte("stale"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := d.multiDownload(context.Background(), url, "go.tar.gz", &downloadState{
+				URL:       url,
+				Size:      int64(len(data)),
+				ETag:      tt.etag,
+				ChunkSize: 256 << 10,
+			})
+			if _, statErr := os.Stat(partDir); !os.IsNotExist(statErr) {
+				t.Errorf("part directory kept: %v", statErr)
+			}
+			if tt.changed {
+				if !errors.Is(err, errRemoteChanged) {
+					t.Fatalf("multiDownload = %v, want %v", err, errRemoteChanged)
+				}
+				if Exists(d.partialPath("go.tar.gz")) || Exists(filepath.Join(d.dir, "go.tar.gz")) {
+					t.Error("a file was written from the changed remote")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("multiDownload: %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(d.dir, "go.tar.gz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("downloaded file differs from the served file")
+			}
+		})
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	d := newTestDownloader(t, 1)
+	saved := &downloadState{
+		URL:       "https://example.com/go.tar.gz",
+		Size:      250,
+		ETag:      `"v1"`,
+		ChunkSize: 100,
+	}
+	partDir := d.getPartDir(saved.URL)
+	if err := os.MkdirAll(partDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	chunks := planChunks(saved.Size, saved.ChunkSize)
+	chunks[0].next = 50
+	if err := os.WriteFile(d.getPartFilename(saved.URL, 0), []byte(strings.Repeat("x", 50)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.saveState(partDir, saved, newChunkQueue(chunks)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *downloadState)
+		resume bool
+	}{
+		{name: "same", modify: func(s *downloadState) {}, resume: true},
+		{name: "size", modify: func(s *downloadState) { s.Size = 300 }},
+		{name: "chunk size", modify: func(s *downloadState) { s.ChunkSize = 128 }},
+		{name: "etag", modify: func(s *downloadState) { s.ETag = `"v2"` }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := *saved
+			tt.modify(&remote)
+			got := d.loadState(partDir, &remote)
+			if !tt.resume {
+				if got != nil {
+					t.Fatalf("loadState resumed %d chunks from a different download", len(got))
+				}
+				return
+			}
+			if len(got) != len(chunks) || got[0].next != 50 {
+				t.Fatalf("loadState = %d chunks, want %d with the first at byte 50", len(got), len(chunks))
+			}
+		})
+	}
+}
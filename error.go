@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type SVError struct {
 	Message string
@@ -27,6 +30,18 @@ func ErrTagEmpty() error {
 	return NewError("tag is empty")
 }
 
+func ErrNoProjectVersion() error {
+	return NewError("no version given and no go.work, go.mod or .go-version found")
+}
+
+func ErrInvalidVersionQuery(query string) error {
+	return NewError(fmt.Sprintf("invalid version %q, expected e.g. 1.22.3, 1.22, ~1.21, \">=1.20 <1.22\", 1.23rc, latest, stable or oldstable", query))
+}
+
+func ErrNoVersionMatches(query string) error {
+	return NewError(fmt.Sprintf("no version matches %q", query))
+}
+
 func ErrURLEmpty() error {
 	return NewError("download URL is empty")
 }
@@ -55,6 +70,43 @@ func ErrChecksumMismatch() error {
 	return NewError("file checksum does not match, file may be corrupted")
 }
 
+// ChecksumError reports an archive whose SHA256 differs from the release
+// index. Quarantined is where the bad file was moved, if anywhere.
+type ChecksumError struct {
+	Name        string
+	Expected    string
+	Actual      string
+	Quarantined string
+}
+
+func (e *ChecksumError) Error() string {
+	msg := fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.Name, e.Expected, e.Actual)
+	if e.Quarantined != "" {
+		msg += ", moved to " + e.Quarantined
+	}
+	return msg
+}
+
+func ErrChecksumMissing(name string) error {
+	return NewError(fmt.Sprintf("no checksum for %s in the release index, refusing to download", name))
+}
+
+func ErrOffline(what string) error {
+	return NewError("offline mode: " + what)
+}
+
+func ErrExtractLimit(name, limit, setting string) error {
+	return NewError(fmt.Sprintf("refusing to extract %s: %s, the archive may be corrupt or malicious (raise %s if it is not)", name, limit, setting))
+}
+
+func ErrLockTimeout(pid int, timeout time.Duration) error {
+	holder := "another sv process"
+	if pid > 0 {
+		holder = fmt.Sprintf("another sv process (pid %d)", pid)
+	}
+	return NewError(fmt.Sprintf("gave up after %s waiting for %s, set SV_LOCK_TIMEOUT to wait longer", timeout, holder))
+}
+
 func ErrUnsupportedCommand() error {
 	return NewError("unsupported command")
 }
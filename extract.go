@@ -0,0 +1,440 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Extract extracts an archive to the destination directory, stopping
+// between entries once ctx is cancelled. Entries that cannot be extracted
+// safely are skipped and listed afterwards.
+func Extract(ctx context.Context, dst, src string) error {
+	PrintCyan("extracting...")
+	x := &extractor{
+		dst:         filepath.Clean(dst),
+		links:       make(map[string]string),
+		maxSize:     cfg.ExtractMaxSize,
+		maxFiles:    cfg.ExtractMaxFiles,
+		maxFileSize: cfg.ExtractMaxFileSize,
+	}
+
+	var err error
+	switch {
+	case strings.HasSuffix(src, ".tar.gz"), strings.HasSuffix(src, ".tgz"):
+		err = x.untar(ctx, src)
+	case strings.HasSuffix(src, ".zip"):
+		err = x.unzip(ctx, src)
+	default:
+		return fmt.Errorf("unsupported archive format: %s", src)
+	}
+	if err != nil {
+		return err
+	}
+	if err := x.finish(); err != nil {
+		return err
+	}
+	x.report(filepath.Base(src))
+	return nil
+}
+
+// extractor writes archive entries below dst with their modes and mtimes,
+// keeping links from pointing outside of it and the archive from expanding
+// beyond the configured limits
+type extractor struct {
+	dst     string
+	links   map[string]string // symlinks created so far and their targets, never written through
+	dirs    []dirEntry        // finished last, adding entries changes a directory's mtime
+	skipped []skippedEntry
+
+	// limits against decompression bombs, 0 disables one
+	maxSize     int64
+	maxFiles    int
+	maxFileSize int64
+
+	written int64
+	entries int
+}
+
+type dirEntry struct {
+	path  string
+	mode  os.FileMode
+	mtime time.Time
+}
+
+type skippedEntry struct {
+	name   string
+	reason string
+}
+
+// maxReportedSkips caps the entries listed by report
+const maxReportedSkips = 20
+
+func (x *extractor) untar(ctx context.Context, src string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header == nil || header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		target, err := x.target(header.Name)
+		if err != nil {
+			return err
+		}
+		if err := x.admit(header.Name, header.Size); err != nil {
+			return err
+		}
+
+		mode := header.FileInfo().Mode().Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(target, mode, header.ModTime)
+		case tar.TypeReg:
+			err = x.file(header.Name, target, tr, mode, header.ModTime)
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, target, header.Linkname)
+		case tar.TypeLink:
+			err = x.hardlink(header.Name, target, header.Linkname)
+		default:
+			x.skip(header.Name, fmt.Sprintf("unsupported entry type %q", header.Typeflag))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) unzip(ctx context.Context, src string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(x.dst, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		target, err := x.target(f.Name)
+		if err != nil {
+			return err
+		}
+		// the declared size fails a bomb early, the copy still counts the
+		// real bytes since the header can lie
+		if err := x.admit(f.Name, int64(min(f.UncompressedSize64, 1<<62))); err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.dir(target, mode.Perm(), f.Modified)
+		case mode&os.ModeSymlink != 0:
+			// the link target is stored as the content of the entry
+			var linkname string
+			if linkname, err = readZipEntry(f); err == nil {
+				err = x.symlink(f.Name, target, linkname)
+			}
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err == nil {
+				err = x.file(f.Name, target, rc, mode.Perm(), f.Modified)
+				rc.Close()
+			}
+		default:
+			x.skip(f.Name, fmt.Sprintf("unsupported file mode %s", mode))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZipEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(data), err
+}
+
+// target returns where the entry name goes, refusing paths that leave dst
+// either directly or through a symlink extracted earlier
+func (x *extractor) target(name string) (string, error) {
+	target := filepath.Join(x.dst, name)
+
+	// Security: prevent path traversal attacks (zip slip)
+	if !strings.HasPrefix(target, x.dst+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal file path: %s", name)
+	}
+	for dir := filepath.Dir(target); len(dir) > len(x.dst); dir = filepath.Dir(dir) {
+		if _, ok := x.links[dir]; ok {
+			return "", fmt.Errorf("illegal file path: %s goes through a symlink", name)
+		}
+	}
+	return target, nil
+}
+
+// maxLinkHops bounds how many symlinks resolving one path may follow, which
+// also ends symlink loops
+const maxLinkHops = 255
+
+// stays reports whether the relative path rel, taken from dir below dst,
+// remains inside dst. Each step is resolved through the symlinks extracted
+// so far, as the file system would, since a ".." after a link leaves the
+// link's target rather than the directory holding it.
+func (x *extractor) stays(dir, rel string) bool {
+	parts := strings.Split(rel, string(os.PathSeparator))
+	for hops := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if dir == x.dst {
+				return false
+			}
+			dir = filepath.Dir(dir)
+			continue
+		}
+
+		next := filepath.Join(dir, part)
+		link, ok := x.links[next]
+		if !ok {
+			dir = next
+			continue
+		}
+		if hops++; hops > maxLinkHops || filepath.IsAbs(link) {
+			return false
+		}
+		parts = append(strings.Split(link, string(os.PathSeparator)), parts...)
+	}
+	return true
+}
+
+// linksStay reports whether every symlink extracted so far still resolves
+// inside dst. A new link can redirect one created before it, so they are
+// checked again each time.
+func (x *extractor) linksStay() bool {
+	for path, link := range x.links {
+		if !x.stays(filepath.Dir(path), link) {
+			return false
+		}
+	}
+	return true
+}
+
+// admit counts an entry against the limits before it is extracted, using
+// the size the archive declares for it
+func (x *extractor) admit(name string, size int64) error {
+	x.entries++
+	if x.maxFiles > 0 && x.entries > x.maxFiles {
+		return ErrExtractLimit(name, fmt.Sprintf("archive has more than %d entries", x.maxFiles), "SV_EXTRACT_MAX_FILES")
+	}
+	return x.checkSize(name, size)
+}
+
+// checkSize fails once an entry of size bytes breaks a size limit
+func (x *extractor) checkSize(name string, size int64) error {
+	if x.maxFileSize > 0 && size > x.maxFileSize {
+		return ErrExtractLimit(name, fmt.Sprintf("file is larger than %s", formatBytes(x.maxFileSize)), "SV_EXTRACT_MAX_FILE_SIZE")
+	}
+	if x.maxSize > 0 && x.written+size > x.maxSize {
+		return ErrExtractLimit(name, fmt.Sprintf("archive expands to more than %s", formatBytes(x.maxSize)), "SV_EXTRACT_MAX_SIZE")
+	}
+	return nil
+}
+
+// allowance is how many bytes the next file may hold, or -1 for no limit
+func (x *extractor) allowance() int64 {
+	allowed := int64(-1)
+	if x.maxFileSize > 0 {
+		allowed = x.maxFileSize
+	}
+	if x.maxSize > 0 && (allowed < 0 || x.maxSize-x.written < allowed) {
+		allowed = x.maxSize - x.written
+	}
+	return allowed
+}
+
+func (x *extractor) skip(name, reason string) {
+	x.skipped = append(x.skipped, skippedEntry{name: name, reason: reason})
+}
+
+func (x *extractor) dir(target string, mode os.FileMode, mtime time.Time) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	x.dirs = append(x.dirs, dirEntry{path: target, mode: mode, mtime: mtime})
+	return nil
+}
+
+func (x *extractor) file(name, target string, r io.Reader, mode os.FileMode, mtime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// never write through whatever an earlier entry left at the same path
+	os.Remove(target)
+	delete(x.links, target)
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	// one byte more than allowed tells a file at the limit from one over it
+	if allowed := x.allowance(); allowed >= 0 {
+		r = io.LimitReader(r, allowed+1)
+	}
+	n, err := io.Copy(f, r)
+	if err == nil {
+		err = x.checkSize(name, n)
+	}
+	x.written += n
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return setMeta(target, orDefault(mode, 0644), mtime)
+}
+
+func (x *extractor) symlink(name, target, linkname string) error {
+	linkname = filepath.FromSlash(linkname)
+	previous, replaced := x.links[target]
+	x.links[target] = linkname
+	if filepath.IsAbs(linkname) || !x.linksStay() {
+		if replaced {
+			x.links[target] = previous
+		} else {
+			delete(x.links, target)
+		}
+		x.skip(name, fmt.Sprintf("symlink to %s points outside the archive", linkname))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	if err := os.Symlink(linkname, target); err != nil {
+		delete(x.links, target)
+		// creating symlinks may need privileges, on Windows in particular
+		x.skip(name, err.Error())
+		return nil
+	}
+	return nil
+}
+
+// hardlink links target to linkname, a file extracted earlier. Where hard
+// links are not supported the file is copied instead.
+func (x *extractor) hardlink(name, target, linkname string) error {
+	source, err := x.target(linkname)
+	if err != nil {
+		x.skip(name, fmt.Sprintf("hard link to %s points outside the archive", linkname))
+		return nil
+	}
+	info, err := os.Lstat(source)
+	if err != nil || !info.Mode().IsRegular() {
+		x.skip(name, fmt.Sprintf("hard link to %s, which is not a file extracted before it", linkname))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	os.Remove(target)
+	delete(x.links, target)
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return x.file(name, target, f, info.Mode().Perm(), info.ModTime())
+}
+
+// finish applies directory modes and mtimes now that nothing is written
+// into them any more. Directories stay writable by their owner, or sv could
+// neither move the tree into place nor uninstall it.
+func (x *extractor) finish() error {
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		d := x.dirs[i]
+		if err := setMeta(d.path, orDefault(d.mode, 0755)|0200, d.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report lists the entries of archive that were skipped
+func (x *extractor) report(archive string) {
+	if len(x.skipped) == 0 {
+		return
+	}
+	PrintYellow(fmt.Sprintf("Skipped %d entries of %s:", len(x.skipped), archive))
+	for i, s := range x.skipped {
+		if i == maxReportedSkips {
+			PrintYellow(fmt.Sprintf("  ... and %d more", len(x.skipped)-i))
+			break
+		}
+		PrintYellow(fmt.Sprintf("  %s: %s", s.name, s.reason))
+	}
+}
+
+func setMeta(path string, mode os.FileMode, mtime time.Time) error {
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	if mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(path, mtime, mtime)
+}
+
+// orDefault replaces a missing mode, which some archivers write, by def
+func orDefault(mode, def os.FileMode) os.FileMode {
+	if mode == 0 {
+		return def
+	}
+	return mode
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is a symlink when link is set, otherwise a directory if name
+// ends in a slash, otherwise an empty file
+type tarEntry struct {
+	name string
+	link string
+}
+
+func writeTestTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg}
+		switch {
+		case e.link != "":
+			h.Typeflag, h.Linkname, h.Mode = tar.TypeSymlink, e.link, 0777
+		case e.name[len(e.name)-1] == '/':
+			h.Typeflag, h.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(t.TempDir(), "test.tar.gz")
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		kept    []string
+		skipped []string
+	}{
+		{
+			name:    "inside",
+			entries: []tarEntry{{name: "go/bin/"}, {name: "go/bin/go"}, {name: "go/gobin", link: "bin/go"}},
+			kept:    []string{"go/gobin"},
+		},
+		{
+			name:    "relative escape",
+			entries: []tarEntry{{name: "go/"}, {name: "go/up", link: "../../etc"}},
+			skipped: []string{"go/up"},
+		},
+		{
+			name:    "absolute",
+			entries: []tarEntry{{name: "go/"}, {name: "go/abs", link: "/etc/passwd"}},
+			skipped: []string{"go/abs"},
+		},
+		{
+			// q points at the root of the destination, so r climbs above it
+			name:    "chain",
+			entries: []tarEntry{{name: "go/p/"}, {name: "go/p/q", link: "../.."}, {name: "go/p/r", link: "q/../.."}},
+			kept:    []string{"go/p/q"},
+			skipped: []string{"go/p/r"},
+		},
+		{
+			// b turns the earlier, harmless looking a into an escape
+			name:    "redirected by a later link",
+			entries: []tarEntry{{name: "go/p/"}, {name: "go/p/a", link: "b/../../.."}, {name: "go/p/b", link: "."}},
+			kept:    []string{"go/p/a"},
+			skipped: []string{"go/p/b"},
+		},
+		{
+			name:    "loop",
+			entries: []tarEntry{{name: "go/"}, {name: "go/x", link: "y"}, {name: "go/y", link: "x"}},
+			kept:    []string{"go/x"},
+			skipped: []string{"go/y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+			if err := Extract(context.Background(), dst, writeTestTar(t, tt.entries)); err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			for _, name := range tt.kept {
+				if _, err := os.Lstat(filepath.Join(dst, name)); err != nil {
+					t.Errorf("%s was not extracted: %v", name, err)
+				}
+			}
+			for _, name := range tt.skipped {
+				if _, err := os.Lstat(filepath.Join(dst, name)); err == nil {
+					t.Errorf("%s was extracted, want it skipped", name)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractThroughSymlink(t *testing.T) {
+	src := writeTestTar(t, []tarEntry{{name: "go/"}, {name: "go/d", link: "."}, {name: "go/d/x"}})
+	if err := Extract(context.Background(), t.TempDir(), src); err == nil {
+		t.Fatal("Extract wrote a file through a symlink")
+	}
+}
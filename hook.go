@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// envHookSource records which version file the hook switched to, so the
+	// hook can tell its own switches apart from a manual sv shell
+	envHookSource = "SV_HOOK_SOURCE"
+	// envHookWarned remembers the version file already reported as missing
+	envHookWarned = "SV_HOOK_WARNED"
+)
+
+const bashHook = `_sv_hook() {
+  local previous_exit_status=$?
+  eval "$({{SV}} hook-env --shell bash{{FLAGS}})"
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_sv_hook;"* ]]; then
+  PROMPT_COMMAND="_sv_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`
+
+const zshHook = `_sv_hook() {
+  eval "$({{SV}} hook-env --shell zsh{{FLAGS}})"
+}
+typeset -ag chpwd_functions
+if (( ! ${chpwd_functions[(I)_sv_hook]} )); then
+  chpwd_functions=(_sv_hook $chpwd_functions)
+fi
+_sv_hook
+`
+
+const fishHook = `function _sv_hook --on-variable PWD
+  {{SV}} hook-env --shell fish{{FLAGS}} | source
+end
+_sv_hook
+`
+
+const powershellHook = `$global:__svOriginalPrompt = $function:prompt
+function global:prompt {
+  & {{SV}} hook-env --shell powershell{{FLAGS}} | Out-String | Invoke-Expression
+  & $global:__svOriginalPrompt
+}
+`
+
+// hookScript returns the snippet that wires hook-env into the given shell
+func hookScript(shell string, flags []string) (string, error) {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashHook
+	case "zsh":
+		script = zshHook
+	case "fish":
+		script = fishHook
+	case "powershell", "pwsh":
+		script = powershellHook
+	default:
+		return "", NewError("unsupported shell: " + shell + ", expected bash, zsh, fish or powershell")
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		self = "sv"
+	}
+	quoted := posixQuote(self)
+	if shell == "powershell" || shell == "pwsh" {
+		quoted = powershellQuote(self)
+	}
+
+	var extra string
+	for _, f := range flags {
+		extra += " --" + f
+	}
+	return strings.NewReplacer("{{SV}}", quoted, "{{FLAGS}}", extra).Replace(script), nil
+}
+
+// hookTarget returns the version requested by the nearest .go-version file.
+// It only reads local files so it is cheap enough to run on every prompt.
+func hookTarget() (tag, source string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", ""
+	}
+	source = findLocalVersionFile(wd)
+	if source == "" {
+		return "", ""
+	}
+	tag, err = parseGoVersionFile(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sv: %v\n", err)
+		return "", ""
+	}
+	return resolveVersionFile(tag), source
+}
+
+// sessionVersion returns the version the shell currently runs, ignoring
+// version files: SV_GO_VERSION if set, otherwise the global version
+func sessionVersion() string {
+	if v := os.Getenv(envGoVersion); v != "" {
+		return normalizeVersionTag(expandAlias(v))
+	}
+	return getGlobalVersion()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostConfig holds the credentials sent to one mirror host
+type hostConfig struct {
+	Token   string            `json:"token"`   // sent as a bearer token
+	Headers map[string]string `json:"headers"` // extra request headers
+}
+
+var (
+	transportOnce   sync.Once
+	sharedTransport http.RoundTripper
+)
+
+// newHTTPClient returns a client on the transport shared by every request sv
+// makes, index, archives and self-upgrade alike. A zero timeout means none.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transportOnce.Do(func() {
+		base, err := baseTransport()
+		if err != nil {
+			Warnf("%v", err)
+			base = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		sharedTransport = &authTransport{
+			base:    base,
+			hosts:   cfg.Hosts,
+			netrc:   loadNetrc(),
+			mirrors: mirrorHosts(cfg.Mirrors),
+		}
+	})
+	return &http.Client{Transport: sharedTransport, Timeout: timeout}
+}
+
+// baseTransport applies the proxy and CA bundle settings
+func baseTransport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return t, fmt.Errorf("invalid proxy %q, using the environment settings", cfg.Proxy)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return t, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return t, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return t, nil
+}
+
+// authTransport adds the configured headers and credentials for the host of
+// each request. It works per request, so a redirect to another host, such
+// as go.dev to dl.google.com, never carries them along.
+type authTransport struct {
+	base    http.RoundTripper
+	hosts   map[string]hostConfig
+	netrc   []netrcEntry
+	mirrors map[string]bool // hosts the netrc default entry may be sent to
+}
+
+// mirrorHosts returns the hosts of the configured https mirrors, leaving out
+// go.dev, which never needs credentials
+func mirrorHosts(mirrors []string) map[string]bool {
+	hosts := make(map[string]bool)
+	for _, mirror := range mirrors {
+		if mirror == goDevDL {
+			continue
+		}
+		u, err := url.Parse(mirror)
+		if err != nil || u.Scheme != "https" {
+			continue
+		}
+		hosts[u.Hostname()] = true
+	}
+	return hosts
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host, ok := t.hosts[req.URL.Host]
+	if !ok {
+		host, ok = t.hosts[req.URL.Hostname()]
+	}
+	// the default entry is not meant for any particular host, so it only goes
+	// to a configured mirror and never in the clear
+	useDefault := req.URL.Scheme == "https" && t.mirrors[req.URL.Hostname()]
+	login, password, hasNetrc := netrcLookup(t.netrc, req.URL.Hostname(), useDefault)
+	if !ok && !hasNetrc {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for name, value := range host.Headers {
+		req.Header.Set(name, value)
+	}
+	if req.Header.Get("Authorization") == "" {
+		switch {
+		case host.Token != "":
+			req.Header.Set("Authorization", "Bearer "+host.Token)
+		case hasNetrc:
+			req.SetBasicAuth(login, password)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// netrcEntry is a machine, or the default entry when machine is empty
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// netrcPath returns $NETRC, or ~/.netrc (~/_netrc on Windows)
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+func loadNetrc() []netrcEntry {
+	path := netrcPath()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseNetrc(string(data))
+}
+
+// parseNetrc reads machine, default, login and password tokens and skips
+// macro definitions
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var current *netrcEntry
+
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+			switch fields[j] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: next()})
+				current = &entries[len(entries)-1]
+			case "default":
+				entries = append(entries, netrcEntry{})
+				current = &entries[len(entries)-1]
+			case "login":
+				if current != nil {
+					current.login = next()
+				}
+			case "password":
+				if current != nil {
+					current.password = next()
+				}
+			case "account":
+				next()
+			case "macdef":
+				// a macro runs until the next blank line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	return entries
+}
+
+// netrcLookup returns the credentials for host, falling back to the default
+// entry when useDefault is set
+func netrcLookup(entries []netrcEntry, host string, useDefault bool) (login, password string, ok bool) {
+	for _, e := range entries {
+		if e.machine == host {
+			return e.login, e.password, true
+		}
+	}
+	if !useDefault {
+		return "", "", false
+	}
+	for _, e := range entries {
+		if e.machine == "" {
+			return e.login, e.password, true
+		}
+	}
+	return "", "", false
+}
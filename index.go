@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexMeta describes a cached copy of the release index
+type indexMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+func indexCachePath(includeAll bool) string {
+	name := "releases"
+	if includeAll {
+		name += "-all"
+	}
+	return filepath.Join(paths.Home, "index", name)
+}
+
+// loadIndexCache returns the cached index and its metadata, or nil when
+// nothing usable is cached
+func loadIndexCache(includeAll bool) ([]GoRelease, *indexMeta) {
+	base := indexCachePath(includeAll)
+	data, err := os.ReadFile(base + ".json")
+	if err != nil {
+		return nil, nil
+	}
+	var releases []GoRelease
+	if err := json.Unmarshal(data, &releases); err != nil {
+		Warnf("Ignoring corrupt release index cache %s: %v", base+".json", err)
+		return nil, nil
+	}
+
+	meta := &indexMeta{}
+	if data, err := os.ReadFile(base + ".meta.json"); err == nil {
+		if err := json.Unmarshal(data, meta); err != nil {
+			meta = &indexMeta{}
+		}
+	}
+	return releases, meta
+}
+
+// saveIndexCache stores the raw index body as served by the mirror
+func saveIndexCache(includeAll bool, body []byte, meta *indexMeta) error {
+	base := indexCachePath(includeAll)
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return err
+	}
+	if body != nil {
+		if err := writeFileAtomic(base+".json", body); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(base+".meta.json", data)
+}
+
+// cachedReleases returns the cached index without any network access. The
+// current-releases list is derived from the full index when only that one
+// is cached.
+func cachedReleases(includeAll bool) ([]GoRelease, error) {
+	if releases, _ := loadIndexCache(includeAll); releases != nil {
+		return releases, nil
+	}
+	if !includeAll {
+		if all, _ := loadIndexCache(true); all != nil {
+			return stableReleases(all), nil
+		}
+	}
+	return nil, ErrOffline("the release index is not cached yet, run sv list -r once while online")
+}
+
+// stableReleases filters a full index down to its stable releases
+func stableReleases(all []GoRelease) []GoRelease {
+	var stable []GoRelease
+	for _, r := range all {
+		if r.Stable {
+			stable = append(stable, r)
+		}
+	}
+	return stable
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -165,6 +165,26 @@ download_sv() {
     log "Installed sv to $INSTALL_DIR/sv"
 }
 
+# Seed config.json with the variables the generated env used to export, so
+# sv env --write keeps the user's GOPROXY. An existing config is left alone.
+seed_config() {
+    local config="$SV_HOME/config.json"
+    if [[ -f "$config" ]]; then
+        if [[ -n "$GOPROXY" ]] && ! grep -q '"GOPROXY"' "$config"; then
+            warn "Add \"GOPROXY\": \"$GOPROXY\" to the env section of $config to keep it in new shells"
+        fi
+        return 0
+    fi
+
+    local env='"GO111MODULE": "auto"'
+    if [[ -n "$GOPROXY" ]]; then
+        local goproxy
+        goproxy=$(printf '%s' "$GOPROXY" | sed 's/\\/\\\\/g; s/"/\\"/g')
+        env="$env, \"GOPROXY\": \"$goproxy\""
+    fi
+    printf '{\n  "env": {%s}\n}\n' "$env" > "$config"
+}
+
 # Generate environment file (original functionality)
 gen_env() {
     log "Generating environment configuration"
@@ -172,6 +192,13 @@ gen_env() {
     # Ensure SV_HOME directory exists
     mkdir -p "$SV_HOME"
 
+    # Newer sv binaries generate env, env.fish, env.nu and env.ps1 themselves,
+    # with the extra variables taken from the env section of config.json
+    seed_config
+    if "$INSTALL_DIR/sv" env --write >/dev/null 2>&1; then
+        return 0
+    fi
+
     # Use user's GOPROXY if set, otherwise use default
     local goproxy="${GOPROXY:-https://proxy.golang.org,direct}"
 
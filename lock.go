@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileLock is an advisory lock on a file under paths.Home/locks that at most
+// one sv process holds at a time. The kernel drops it when the holder exits,
+// so a crashed sv never leaves a stale lock behind. Locks are reentrant
+// within the process, code holding a version lock may call into code that
+// takes it again.
+type fileLock struct {
+	name   string
+	f      *os.File
+	refs   int
+	waited bool // another process held the lock when it was first requested
+}
+
+var (
+	locksMu   sync.Mutex
+	heldLocks = make(map[string]*fileLock)
+)
+
+// lockSwitch serializes changes to the paths.Root symlink
+func lockSwitch(ctx context.Context) (*fileLock, error) {
+	return acquireLock(ctx, "switch")
+}
+
+// lockAliases serializes read-modify-write updates of aliases.json
+func lockAliases(ctx context.Context) (*fileLock, error) {
+	return acquireLock(ctx, "aliases")
+}
+
+// lockVersion serializes downloading, extracting and removing one version
+func lockVersion(ctx context.Context, tag string) (*fileLock, error) {
+	return acquireLock(ctx, "version-"+normalizeVersionTag(tag))
+}
+
+// acquireLock takes the named lock, waiting up to cfg.LockTimeout for
+// another sv process to release it
+func acquireLock(ctx context.Context, name string) (*fileLock, error) {
+	locksMu.Lock()
+	if l, ok := heldLocks[name]; ok {
+		l.refs++
+		locksMu.Unlock()
+		return l, nil
+	}
+	locksMu.Unlock()
+
+	dir := filepath.Join(paths.Home, "locks")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	path := filepath.Join(dir, name+".lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	l := &fileLock{name: name, f: f, refs: 1}
+	if err := l.wait(ctx, path); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// record the holder for processes that have to wait
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+
+	locksMu.Lock()
+	heldLocks[name] = l
+	locksMu.Unlock()
+	return l, nil
+}
+
+func (l *fileLock) wait(ctx context.Context, path string) error {
+	ok, err := tryLockFile(l.f)
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	if ok {
+		return nil
+	}
+
+	l.waited = true
+	pid := lockHolder(path)
+	if pid > 0 {
+		PrintYellow(fmt.Sprintf("Waiting for another sv process (pid %d)...", pid))
+	} else {
+		PrintYellow("Waiting for another sv process...")
+	}
+
+	var timeout <-chan time.Time
+	if cfg.LockTimeout > 0 {
+		timer := time.NewTimer(cfg.LockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return ErrLockTimeout(pid, cfg.LockTimeout)
+		case <-ticker.C:
+			ok, err := tryLockFile(l.f)
+			if err != nil {
+				return fmt.Errorf("failed to lock %s: %w", path, err)
+			}
+			if ok {
+				return nil
+			}
+		}
+	}
+}
+
+// release drops one reference and unlocks once the last one is gone
+func (l *fileLock) release() {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	if l.refs--; l.refs > 0 {
+		return
+	}
+	delete(heldLocks, l.name)
+	unlockFile(l.f)
+	l.f.Close()
+}
+
+// lockHolder returns the pid recorded in a lock file, or 0
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
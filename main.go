@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/urfave/cli/v2"
 )
 
 const Ver = "v1.2.3"
 
+// exitInterrupted is the status sv exits with when stopped by Ctrl-C or
+// SIGTERM, following the shell convention of 128 + SIGINT
+const exitInterrupted = 130
+
 // Paths holds all application directory paths
 type Paths struct {
 	Home     string // ~/.sv
@@ -49,6 +57,10 @@ func initPaths() error {
 }
 
 func main() {
+	if name, ok := shimName(); ok {
+		os.Exit(runShim(name, os.Args[1:]))
+	}
+
 	SetLogLevel("debug")
 	app := cli.NewApp()
 	app.Usage = "switch version"
@@ -73,12 +85,12 @@ func main() {
 		}, {
 			Name:      "use",
 			Usage:     "switch to a specific Go version",
-			UsageText: "sv use <version>",
+			UsageText: "sv use [version]",
 			Action:    baseCmd,
 		}, {
 			Name:      "install",
 			Usage:     "install a specific remote version",
-			UsageText: "sv install <version>",
+			UsageText: "sv install [version] | sv install --from-file <archive> [--sha256 <sum>]",
 			Action:    baseCmd,
 			Aliases:   []string{"i"},
 			Flags: []cli.Flag{
@@ -86,6 +98,157 @@ func main() {
 					Name:  "latest",
 					Usage: "install the latest version",
 				},
+				&cli.StringFlag{
+					Name:  "from-file",
+					Usage: "install from a local official archive without network access",
+				},
+				&cli.StringFlag{
+					Name:  "sha256",
+					Usage: "expected SHA256 of the --from-file archive",
+				},
+			},
+		}, {
+			Name:      "local",
+			Usage:     "pin a Go version for the current directory",
+			UsageText: "sv local [version] [--unset]",
+			Action:    baseCmd,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "unset",
+					Usage: "remove the version file from the current directory",
+				},
+			},
+		}, {
+			Name:            "exec",
+			Usage:           "run a command under a specific Go version",
+			UsageText:       "sv exec <version> -- <command> [args...]",
+			Action:          baseCmd,
+			SkipFlagParsing: true,
+		}, {
+			Name:      "shell",
+			Usage:     "print exports that switch the Go version of the current shell only",
+			UsageText: "eval \"$(sv shell <version>)\"",
+			Action:    baseCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "shell",
+					Usage: "shell syntax to print: bash, zsh, fish or powershell (default: detected)",
+				},
+				&cli.BoolFlag{
+					Name:  "unset",
+					Usage: "go back to the global version",
+				},
+			},
+		}, {
+			Name:      "env",
+			Usage:     "print the environment for a Go version, or regenerate ~/.sv/env",
+			UsageText: "sv env [version] [--shell bash|zsh|fish|powershell|nushell|json] | sv env --write",
+			Action:    baseCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "shell",
+					Usage: "output format: bash, zsh, fish, powershell, nushell or json (default: detected)",
+				},
+				&cli.BoolFlag{
+					Name:  "write",
+					Usage: "regenerate ~/.sv/env, env.fish, env.nu and env.ps1",
+				},
+			},
+		}, {
+			Name:  "mirror",
+			Usage: "share installed archives with other machines",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "serve",
+					Usage:     "serve downloaded archives as a go.dev/dl compatible mirror",
+					UsageText: "sv mirror serve [--addr :8080] [--dir <path>]",
+					Action:    baseCmd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "addr",
+							Usage: "address to listen on",
+							Value: ":8080",
+						},
+						&cli.StringFlag{
+							Name:  "dir",
+							Usage: "directory of archives to serve (default: ~/.sv/downloads)",
+						},
+					},
+				},
+				{
+					Name:      "sync",
+					Usage:     "download archives for chosen versions and platforms into a mirror directory",
+					UsageText: "sv mirror sync --versions '>=1.21' --platform linux/amd64,darwin/arm64 --dir <path>",
+					Action:    baseCmd,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "versions",
+							Usage: "version query selecting the releases to mirror",
+							Value: queryLatest,
+						},
+						&cli.StringFlag{
+							Name:  "platform",
+							Usage: "comma separated os/arch list (default: current platform)",
+						},
+						&cli.StringFlag{
+							Name:     "dir",
+							Usage:    "mirror directory to fill",
+							Required: true,
+						},
+					},
+				},
+			},
+		}, {
+			Name:      "hook",
+			Usage:     "print a shell hook that switches versions on cd",
+			UsageText: "eval \"$(sv hook bash)\" | sv hook fish | source",
+			Action:    baseCmd,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "install",
+					Usage: "install missing versions automatically",
+				},
+				&cli.BoolFlag{
+					Name:  "global",
+					Usage: "switch the global version instead of exporting env for the shell",
+				},
+			},
+		}, {
+			Name:   "hook-env",
+			Usage:  "print env changes for the current directory (used by sv hook)",
+			Action: baseCmd,
+			Hidden: true,
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "shell"},
+				&cli.BoolFlag{Name: "install"},
+				&cli.BoolFlag{Name: "global"},
+			},
+		}, {
+			Name:      "alias",
+			Usage:     "manage named aliases for installed versions",
+			UsageText: "sv alias [<name> <version>] | set | rm | ls",
+			Action:    baseCmd,
+			Subcommands: []*cli.Command{
+				{
+					Name:      "set",
+					Usage:     "point an alias at an installed version",
+					UsageText: "sv alias set <name> <version>",
+					Action:    baseCmd,
+				},
+				{
+					Name:      "rm",
+					Usage:     "remove an alias",
+					UsageText: "sv alias rm <name>",
+					Action:    baseCmd,
+					Aliases:   []string{"remove"},
+				},
+				{
+					Name:      "ls",
+					Usage:     "list aliases",
+					UsageText: "sv alias ls",
+					Action:    baseCmd,
+					Aliases:   []string{"list"},
+				},
 			},
 		}, {
 			Name:      "uninstall",
@@ -162,11 +325,40 @@ func main() {
 			},
 		},
 	}
+	app.Flags = []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "use only the cached release index and local archives (or set SV_OFFLINE=1)",
+		},
+		&cli.StringFlag{
+			Name:  "limit-rate",
+			Usage: "limit the total download bandwidth, e.g. 500K or 5M (or set SV_LIMIT_RATE)",
+		},
+	}
 	app.Before = func(context *cli.Context) error {
+		if context.Bool("offline") {
+			cfg.Offline = true
+		}
+		if rate := context.String("limit-rate"); rate != "" {
+			limit, err := parseSize(rate)
+			if err != nil {
+				return err
+			}
+			cfg.LimitRate = limit
+		}
 		return initPaths()
 	}
 
-	err := app.Run(os.Args)
+	// Cancelled on Ctrl-C so downloads and extraction stop at a safe point
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// a second Ctrl-C kills sv right away
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := app.RunContext(ctx, os.Args)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -174,6 +366,15 @@ func main() {
 
 func baseCmd(c *cli.Context) error {
 	if err := newApp(c).Run(); err != nil {
+		// Exit codes of commands run by sv are passed through untouched
+		var exitErr cli.ExitCoder
+		if errors.As(err, &exitErr) {
+			return exitErr
+		}
+		if c.Context.Err() != nil {
+			PrintYellow("Interrupted")
+			return cli.Exit("", exitInterrupted)
+		}
 		PrintError(err)
 		return cli.Exit("", 1)
 	}
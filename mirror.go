@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// mirrorIndexFile is the static index name served next to the archives
+const mirrorIndexFile = "index.json"
+
+// mirrorServer serves the archives of a directory as a go.dev/dl compatible
+// mirror: a ?mode=json release index plus the archives with range support
+type mirrorServer struct {
+	dir string
+}
+
+func newMirrorServer(dir string) *mirrorServer {
+	return &mirrorServer{dir: dir}
+}
+
+// index builds the release index from the verified archives in the directory,
+// those with a .sha256 file next to them. Partial or unverified files are
+// left out, and the checksums come from the .sha256 files rather than from
+// hashing every archive again.
+func (m *mirrorServer) index() ([]GoRelease, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byTag := make(map[string]*GoRelease)
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		tag, goos, goarch, _, ok := parseArchiveName(e.Name())
+		if !ok {
+			continue
+		}
+		archive := filepath.Join(m.dir, e.Name())
+		if !Exists(archive + checksumSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		sum, err := readChecksumFile(archive)
+		if err != nil {
+			Warnf("Skipping %s: %v", e.Name(), err)
+			continue
+		}
+
+		r, ok := byTag[tag]
+		if !ok {
+			v, _ := parseGoVersion(tag)
+			r = &GoRelease{Version: tag, Stable: v.Pre == ""}
+			byTag[tag] = r
+		}
+		r.Files = append(r.Files, GoFile{
+			Filename: e.Name(),
+			OS:       goos,
+			Arch:     goarch,
+			SHA256:   sum,
+			Size:     info.Size(),
+			Kind:     "archive",
+		})
+	}
+
+	releases := make([]GoRelease, 0, len(byTag))
+	for _, r := range byTag {
+		releases = append(releases, *r)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return versionCompare(releases[i].Version) > versionCompare(releases[j].Version)
+	})
+	return releases, nil
+}
+
+// writeIndex stores the current index as a static index.json in the directory
+// so any file server, or sv itself, can use it as a mirror
+func (m *mirrorServer) writeIndex() ([]GoRelease, error) {
+	releases, err := m.index()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(releases, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return releases, writeFileAtomic(filepath.Join(m.dir, mirrorIndexFile), data)
+}
+
+func (m *mirrorServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case name == "" && r.URL.Query().Get("mode") == "json", name == mirrorIndexFile:
+		m.serveIndex(w)
+	case name == "":
+		m.serveListing(w)
+	default:
+		m.serveArchive(w, r, name)
+	}
+}
+
+func (m *mirrorServer) serveIndex(w http.ResponseWriter) {
+	releases, err := m.index()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	enc.Encode(releases)
+}
+
+func (m *mirrorServer) serveListing(w http.ResponseWriter) {
+	releases, err := m.index()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintln(w, "<!doctype html><title>sv mirror</title><ul>")
+	for _, r := range releases {
+		for _, f := range r.Files {
+			name := html.EscapeString(f.Filename)
+			fmt.Fprintf(w, "<li><a href=\"%s\">%s</a> %s</li>\n", name, name, formatBytes(f.Size))
+		}
+	}
+	fmt.Fprintln(w, "</ul>")
+}
+
+// serveArchive only serves official archive names, so nothing else in the
+// directory leaks out. ServeContent handles HEAD and Range requests.
+func (m *mirrorServer) serveArchive(w http.ResponseWriter, r *http.Request, name string) {
+	if _, _, _, _, ok := parseArchiveName(name); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(filepath.Join(m.dir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// readMirrorIndex reads the static index of a local mirror directory
+func readMirrorIndex(dir string, includeAll bool) ([]GoRelease, error) {
+	data, err := os.ReadFile(filepath.Join(dir, mirrorIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var releases []GoRelease
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, mirrorIndexFile), err)
+	}
+	if !includeAll {
+		releases = stableReleases(releases)
+	}
+	return releases, nil
+}
+
+// parsePlatforms parses a comma separated list of os/arch pairs, defaulting
+// to the current platform
+func parsePlatforms(s string) ([][2]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return [][2]string{{runtime.GOOS, runtime.GOARCH}}, nil
+	}
+	var platforms [][2]string
+	for _, item := range strings.Split(s, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(item), "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, NewError(fmt.Sprintf("invalid platform %q, expected os/arch such as linux/amd64", item))
+		}
+		platforms = append(platforms, [2]string{goos, goarch})
+	}
+	return platforms, nil
+}
+
+// syncMirror downloads the archives of releases for the given platforms into
+// dir, verifying every one against the index, then rewrites index.json.
+// Archives already present with the right checksum are kept.
+func syncMirror(ctx context.Context, dir string, releases []GoRelease, platforms [][2]string) (fetched, kept int, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, 0, err
+	}
+	m := newMirrorServer(dir)
+
+	var failed []string
+	for _, r := range releases {
+		for _, f := range r.Files {
+			if f.Kind != "archive" || !platformWanted(platforms, f.OS, f.Arch) {
+				continue
+			}
+			if f.SHA256 == "" {
+				Warnf("Skipping %s: %v", f.Filename, ErrChecksumMissing(f.Filename))
+				failed = append(failed, f.Filename)
+				continue
+			}
+
+			dst := filepath.Join(dir, f.Filename)
+			if sum, err := fileSHA256(dst); err == nil && sum == f.SHA256 {
+				if err := writeChecksumFile(dst, sum); err != nil {
+					Warnf("Failed to record the checksum of %s: %v", f.Filename, err)
+				}
+				kept++
+				continue
+			}
+
+			d := NewDownloader(cfg.DownloadConcurrency, r.Version)
+			d.dir = dir
+			d.checksum = f.SHA256
+			if err := downloadVerified(ctx, d, f.DownloadURLs(), f.Filename); err != nil {
+				if ctx.Err() != nil {
+					return fetched, kept, err
+				}
+				Warnf("Failed to download %s: %v", f.Filename, err)
+				failed = append(failed, f.Filename)
+				continue
+			}
+			fetched++
+		}
+	}
+
+	if _, err := m.writeIndex(); err != nil {
+		return fetched, kept, fmt.Errorf("failed to write %s: %w", mirrorIndexFile, err)
+	}
+	if len(failed) > 0 {
+		return fetched, kept, NewError(fmt.Sprintf("%d archive(s) failed to sync: %s", len(failed), strings.Join(failed, ", ")))
+	}
+	return fetched, kept, nil
+}
+
+func platformWanted(platforms [][2]string, goos, goarch string) bool {
+	for _, p := range platforms {
+		if p[0] == goos && p[1] == goarch {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,122 +11,377 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 type Package struct {
 	Name      string
 	Tag       string
 	URL       string
+	Mirrors   []string // fallback URLs tried in order when URL is unavailable
 	Kind      string
 	OS        string
 	Arch      string
 	Checksum  string // SHA256
 	Algorithm string
+	verified  bool // the archive in paths.Download was hashed as it was written
 }
 
-func (p *Package) download() error {
+func (p *Package) download(ctx context.Context) error {
 	if p.URL == "" || p.Name == "" {
 		return ErrURLEmpty()
 	}
+	p.adoptDownload()
+	urls := append([]string{p.URL}, p.Mirrors...)
+	if cfg.Offline {
+		if inDownload(p.Name) {
+			return nil
+		}
+		// Local mirror directories are still reachable offline
+		var local []string
+		for _, u := range urls {
+			if _, ok := localMirrorPath(u); ok {
+				local = append(local, u)
+			}
+		}
+		if len(local) == 0 {
+			return ErrOffline(fmt.Sprintf("%s is not in %s and cannot be downloaded", p.Name, paths.Download))
+		}
+		urls = local
+	}
+	// Mirrors are not trusted, every archive must be checked against the index
+	if p.Checksum == "" {
+		return ErrChecksumMissing(p.Name)
+	}
 
-	d := NewDownloader(runtime.NumCPU(), p.Tag)
+	d := NewDownloader(cfg.DownloadConcurrency, p.Tag)
+	d.checksum = p.Checksum
+	if err := downloadVerified(ctx, d, urls, p.Name); err != nil {
+		return err
+	}
+	p.verified = true
+	return nil
+}
+
+// downloadVerified downloads name and checks it against d.checksum. A
+// corrupt download is quarantined and fetched once more from scratch before
+// giving up.
+func downloadVerified(ctx context.Context, d *Downloader, urls []string, name string) error {
+	err := downloadFrom(ctx, d, urls, name)
+	var mismatch *ChecksumError
+	if !errors.As(err, &mismatch) {
+		return err
+	}
+	Warnf("%v", mismatch)
+	PrintYellow("Downloading a fresh copy of " + name)
+	if err := downloadFrom(ctx, d, urls, name); err != nil {
+		return fmt.Errorf("second download failed too: %w", err)
+	}
+	return nil
+}
 
-	return retryFunc(func() error {
-		return d.Download(p.URL, p.Name)
-	}, cfg.DownloadRetry)
+// downloadFrom fetches name with d from the first of urls that works, moving
+// on to the next one when a mirror is unavailable or serves an archive that
+// does not match the index. A mismatch is not retried on the same mirror,
+// downloading the same bytes again cannot fix it.
+func downloadFrom(ctx context.Context, d *Downloader, urls []string, name string) error {
+	var err error
+	for i, u := range urls {
+		var mismatch *ChecksumError
+		err = retryFunc(ctx, func() error {
+			err := d.Download(ctx, u, name)
+			if errors.As(err, &mismatch) {
+				return nil
+			}
+			return err
+		}, cfg.DownloadRetry)
+		if mismatch != nil {
+			err = mismatch
+			if i == len(urls)-1 {
+				break
+			}
+			Warnf("%v, trying %s", mismatch, urls[i+1])
+			continue
+		}
+		if err == nil || ctx.Err() != nil || !shouldFailover(err) || i == len(urls)-1 {
+			break
+		}
+		Warnf("Download from %s failed: %v, trying %s", u, err, urls[i+1])
+	}
+	return err
 }
 
+// verifyChecksum checks an archive that was not hashed while downloading
+// in this run against the index, or against its checksum file when the
+// index is not at hand. A corrupt archive is quarantined so the next attempt
+// downloads it again.
 func (p *Package) verifyChecksum() error {
-	if p.Checksum == "" {
+	if p.verified {
 		return nil
 	}
+	archive := filepath.Join(paths.Download, p.Name)
+	expected := p.Checksum
+	if expected == "" {
+		sum, err := readChecksumFile(archive)
+		if err != nil {
+			return err
+		}
+		expected = sum
+	}
+
+	computed, err := fileSHA256(archive)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(expected, computed) {
+		os.Remove(archive + checksumSuffix)
+		return checksumFailed(archive, p.Name, expected, computed)
+	}
+	return nil
+}
+
+// adoptDownload accepts an archive left without a checksum file, by an
+// older sv or a crash just before the checksum file was written, if it
+// matches the index
+func (p *Package) adoptDownload() {
+	archive := filepath.Join(paths.Download, p.Name)
+	if p.Checksum == "" || inDownload(p.Name) || !Exists(archive) {
+		return
+	}
+	if sum, err := fileSHA256(archive); err == nil && strings.EqualFold(sum, p.Checksum) {
+		if writeChecksumFile(archive, sum) == nil {
+			p.verified = true
+		}
+	}
+}
+
+// checksumSuffix names the file recording the SHA256 an archive in
+// paths.Download was verified with, in sha256sum format
+const checksumSuffix = ".sha256"
 
-	filePath := filepath.Join(paths.Download, p.Name)
-	f, err := os.Open(filePath)
+func writeChecksumFile(archive, sum string) error {
+	return writeFileAtomic(archive+checksumSuffix, []byte(sum+"  "+filepath.Base(archive)+"\n"))
+}
+
+func readChecksumFile(archive string) (string, error) {
+	data, err := os.ReadFile(archive + checksumSuffix)
 	if err != nil {
-		return fmt.Errorf("failed to open file for checksum: %w", err)
+		return "", fmt.Errorf("no verified checksum for %s: %w", filepath.Base(archive), err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file %s", archive+checksumSuffix)
+	}
+	return fields[0], nil
+}
+
+// checksumFailed moves the corrupt file at src out of the way into the
+// .quarantine directory next to it and describes what happened
+func checksumFailed(src, name, expected, actual string) error {
+	err := &ChecksumError{Name: name, Expected: expected, Actual: actual}
+	quarantine := filepath.Join(filepath.Dir(src), ".quarantine")
+	dst := filepath.Join(quarantine, name+"."+time.Now().Format("20060102-150405.000"))
+	if os.MkdirAll(quarantine, 0755) == nil && os.Rename(src, dst) == nil {
+		err.Quarantined = dst
+	} else {
+		os.Remove(src)
+	}
+	return err
+}
+
+// fileSHA256 returns the hex encoded SHA256 of the file at path
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for checksum: %w", err)
 	}
 	defer f.Close()
 
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		return fmt.Errorf("failed to read file for checksum: %w", err)
-	}
-
-	computed := fmt.Sprintf("%x", h.Sum(nil))
-	if p.Checksum != computed {
-		return ErrChecksumMismatch()
+		return "", fmt.Errorf("failed to read file for checksum: %w", err)
 	}
-	return nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func (p *Package) useCached() error {
+func (p *Package) useCached(ctx context.Context) error {
 	tag := normalizeVersionTag(p.Tag)
-	return execute(tag)
+	return execute(ctx, tag)
 }
 
-func (p *Package) useDownloaded() error {
+// unpack verifies the downloaded archive and extracts it into paths.Cache/<tag>.
+// The archive is extracted into a staging directory of its own and the
+// GOROOT moved into place with one rename, so concurrent installs never
+// share a directory and a crash never leaves a partial version behind. A
+// version already installed stays in use until the new copy is complete.
+func (p *Package) unpack(ctx context.Context) error {
 	if err := p.verifyChecksum(); err != nil {
 		return err
 	}
+	cleanStaging()
 
-	if err := Extract(paths.Cache, filepath.Join(paths.Download, p.Name)); err != nil {
+	staging, err := os.MkdirTemp(paths.Cache, stagingPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := Extract(ctx, staging, filepath.Join(paths.Download, p.Name)); err != nil {
 		return err
 	}
+	goroot := filepath.Join(staging, "go")
+	if !isGoroot(goroot) {
+		return NewError(fmt.Sprintf("%s does not contain a Go installation", p.Name))
+	}
 	PrintGreen("extract success")
 
-	normalizedTag := normalizeVersionTag(p.Tag)
-	if err := os.Rename(filepath.Join(paths.Cache, "go"), filepath.Join(paths.Cache, normalizedTag)); err != nil {
-		return err
+	dst := filepath.Join(paths.Cache, normalizeVersionTag(p.Tag))
+	// the old copy goes into the staging directory, removed along with it
+	previous := filepath.Join(staging, "previous")
+	if _, err := os.Lstat(dst); err == nil {
+		if err := os.Rename(dst, previous); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", p.Tag, err)
+		}
+	}
+	if err := os.Rename(goroot, dst); err != nil {
+		if Exists(previous) {
+			os.Rename(previous, dst)
+		}
+		return fmt.Errorf("failed to install %s: %w", p.Tag, err)
+	}
+	return nil
+}
+
+// stagingPrefix starts the name of the directories archives are extracted
+// into. The dot keeps them, like everything else hidden in paths.Cache,
+// out of the installed versions.
+const stagingPrefix = ".extract-"
+
+// staleStagingAge is how old a staging directory, or a cache/go left by an
+// sv that extracted in place, has to be before it counts as abandoned
+// rather than in use by another run
+const staleStagingAge = time.Hour
+
+// cleanStaging removes what aborted extractions left in paths.Cache
+func cleanStaging() {
+	entries, err := os.ReadDir(paths.Cache)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || (!strings.HasPrefix(e.Name(), stagingPrefix) && e.Name() != "go") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < staleStagingAge {
+			continue
+		}
+		path := filepath.Join(paths.Cache, e.Name())
+		if err := os.RemoveAll(path); err != nil {
+			Warnf("Failed to remove leftover %s: %v", path, err)
+		}
 	}
+}
 
-	return p.useCached()
+// isGoroot reports whether dir looks like a complete Go installation
+func isGoroot(dir string) bool {
+	goBin := filepath.Join(dir, "bin", "go")
+	if runtime.GOOS == "windows" {
+		goBin += ".exe"
+	}
+	return Exists(goBin) && Exists(filepath.Join(dir, "VERSION"))
 }
 
-func (p *Package) useRemote() error {
-	if err := p.download(); err != nil {
+func (p *Package) useDownloaded(ctx context.Context) error {
+	if err := p.unpack(ctx); err != nil {
 		return err
 	}
-	return p.useDownloaded()
+	return p.useCached(ctx)
+}
+
+func (p *Package) useRemote(ctx context.Context) error {
+	lock, err := lockVersion(ctx, p.Tag)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	if err := p.download(ctx); err != nil {
+		return err
+	}
+	return p.useDownloaded(ctx)
 }
 
 // useLocal contain cached and downloaded
-func (p *Package) useLocal() error {
+func (p *Package) useLocal(ctx context.Context) error {
+	lock, err := lockVersion(ctx, p.Tag)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
 	normalizedTag := normalizeVersionTag(p.Tag)
 	if inCache(normalizedTag) {
-		return p.useCached()
+		return p.useCached(ctx)
 	}
 	if inDownload(p.Name) {
-		return p.useDownloaded()
+		return p.useDownloaded(ctx)
 	}
 	return ErrLocalNotExist()
 }
 
-func (p *Package) use() (err error) {
-	if err := p.useLocal(); err != nil {
-		return p.useRemote()
+func (p *Package) use(ctx context.Context) (err error) {
+	lock, err := lockVersion(ctx, p.Tag)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	if err := p.useLocal(ctx); err != nil {
+		return p.useRemote(ctx)
 	}
 	return
 }
 
-func (p *Package) install() error {
-	if err := p.download(); err != nil {
+func (p *Package) install(ctx context.Context) error {
+	lock, err := lockVersion(ctx, p.Tag)
+	if err != nil {
 		return err
 	}
+	defer lock.release()
 
 	tag := normalizeVersionTag(p.Tag)
-	os.RemoveAll(filepath.Join(paths.Cache, tag))
+	// another sv process was installing the same version, use its result
+	if lock.waited && inCache(tag) {
+		PrintCyan(fmt.Sprintf("%s was installed by another sv process", tag))
+		return p.useCached(ctx)
+	}
 
-	return p.useDownloaded()
+	if err := p.download(ctx); err != nil {
+		return err
+	}
+	return p.useDownloaded(ctx)
 }
 
-func (p *Package) remove() error {
-	return p.removeLocal()
+func (p *Package) remove(ctx context.Context) error {
+	return p.removeLocal(ctx)
 }
 
-func (p *Package) removeLocal() error {
+func (p *Package) removeLocal(ctx context.Context) error {
 	tag := normalizeVersionTag(p.Tag)
 
+	lock, err := lockVersion(ctx, tag)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	// no switch to this version may happen while it is being removed
+	switchLock, err := lockSwitch(ctx)
+	if err != nil {
+		return err
+	}
+	defer switchLock.release()
+
 	linkPath, err := os.Readlink(paths.Root)
 	if err == nil && filepath.Base(linkPath) == tag {
 		return ErrVersionInUse(tag)
@@ -135,52 +392,112 @@ func (p *Package) removeLocal() error {
 	}
 
 	os.RemoveAll(filepath.Join(paths.Download, p.Name))
+	os.Remove(filepath.Join(paths.Download, p.Name+checksumSuffix))
 
 	return nil
 }
 
+// getLocalVersion lists the installed versions, leaving out staging
+// directories and anything else in paths.Cache that is not a GOROOT
 func (p *Package) getLocalVersion() (versions []string, err error) {
-	folder := filepath.Join(paths.Cache, "*")
-	versions, err = filepath.Glob(folder)
+	entries, err := os.ReadDir(paths.Cache)
 	if err != nil {
-		return
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	for i, v := range versions {
-		versions[i] = filepath.Base(v)
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || name == "go" {
+			continue
+		}
+		if isGoroot(filepath.Join(paths.Cache, name)) {
+			versions = append(versions, name)
+		}
 	}
 	return
 }
 
-func execute(tag string) (err error) {
-	if err = os.RemoveAll(paths.Root); err != nil {
-		return fmt.Errorf("failed to remove existing Go installation: %w", err)
+func execute(ctx context.Context, tag string) (err error) {
+	lock, err := lockSwitch(ctx)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	if err = os.Symlink(filepath.Join(paths.Cache, tag), paths.Root); err != nil {
+	previous, _ := os.Readlink(paths.Root)
+	if err := switchRoot(filepath.Join(paths.Cache, tag)); err != nil {
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
 
 	goBin := filepath.Join(paths.Root, "bin", "go")
-	cmd := exec.Command(goBin, "version")
+	cmd := exec.CommandContext(ctx, goBin, "version")
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	newPath := filepath.Join(paths.Root, "bin")
-	if p := os.Getenv("PATH"); p != "" {
-		newPath += string(filepath.ListSeparator) + p
-	}
-	cmd.Env = dedupEnv(append(os.Environ(), "GOROOT="+paths.Root, "PATH="+newPath))
+	cmd.Env = goEnv(paths.Root)
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to execute go version: %w", err)
+		// an interrupted check says nothing about the new version
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if previous != "" {
+			if rerr := switchRoot(previous); rerr != nil {
+				Warnf("Failed to restore %s: %v", filepath.Base(previous), rerr)
+			} else {
+				PrintYellow(fmt.Sprintf("Restored %s", filepath.Base(previous)))
+			}
+		}
+		return fmt.Errorf("failed to execute go version: %v", err)
+	}
+	return nil
+}
+
+// switchRoot points paths.Root at target by renaming a new symlink over the
+// old one, so other terminals always find a Go installation there
+func switchRoot(target string) error {
+	tmp := filepath.Join(filepath.Dir(paths.Root), fmt.Sprintf(".%s-%d.tmp", filepath.Base(paths.Root), os.Getpid()))
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, paths.Root); err != nil {
+		// a directory in the way, or Windows, where a rename does not
+		// replace a directory link
+		if rmErr := os.RemoveAll(paths.Root); rmErr == nil {
+			err = os.Rename(tmp, paths.Root)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
 	}
 	return nil
 }
 
+// inDownload reports whether a verified archive is in paths.Download. An
+// archive without its checksum file may be incomplete and does not count.
 func inDownload(name string) bool {
-	return Exists(filepath.Join(paths.Download, name))
+	archive := filepath.Join(paths.Download, name)
+	return Exists(archive) && Exists(archive+checksumSuffix)
 }
 
 func inCache(tag string) bool {
-	return Exists(filepath.Join(paths.Cache, tag))
+	return isGoroot(filepath.Join(paths.Cache, tag))
+}
+
+// goEnv returns the current environment with GOROOT set to goroot and its bin
+// directory prepended to PATH
+func goEnv(goroot string) []string {
+	newPath := filepath.Join(goroot, "bin")
+	if p := os.Getenv("PATH"); p != "" {
+		newPath += string(filepath.ListSeparator) + p
+	}
+	return dedupEnv(append(os.Environ(), "GOROOT="+goroot, "PATH="+newPath))
 }
 
 // dedupEnv removes duplicate environment variables, keeping the last value
@@ -203,6 +520,22 @@ func dedupEnv(env []string) []string {
 
 // getCurrentVersion returns the currently active Go version
 func getCurrentVersion() string {
+	tag, _ := currentVersion()
+	return tag
+}
+
+// currentVersion returns the Go version active in the working directory and
+// where it was selected, see resolveVersion
+func currentVersion() (tag, source string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return getGlobalVersion(), paths.Root
+	}
+	return resolveVersion(wd)
+}
+
+// getGlobalVersion returns the version paths.Root points to
+func getGlobalVersion() string {
 	linkPath, err := os.Readlink(paths.Root)
 	if err != nil {
 		return ""
@@ -46,6 +46,7 @@ type Bar struct {
 	name   string
 	status string
 	total  int64
+	limit  int64 // bandwidth limit shown next to the speed, 0 for none
 
 	current  atomic.Int64
 	speed    float64 // bytes per second (smoothed)
@@ -80,6 +81,13 @@ func (b *Bar) SetName(name, color string) {
 	b.mu.Unlock()
 }
 
+// SetLimit shows the bandwidth limit the download is throttled to
+func (b *Bar) SetLimit(bytesPerSec int64) {
+	b.mu.Lock()
+	b.limit = bytesPerSec
+	b.mu.Unlock()
+}
+
 // Add increases the current progress
 func (b *Bar) Add(n int64) {
 	newVal := b.current.Add(n)
@@ -103,6 +111,20 @@ func (b *Bar) Close() {
 	}
 }
 
+// Stop ends an unfinished bar, leaving its last state on its own line so
+// whatever is printed next starts cleanly
+func (b *Bar) Stop() {
+	if !b.closed.CompareAndSwap(false, true) {
+		return
+	}
+	close(b.done)
+	b.mu.Lock()
+	b.status = Yellow("Stopped")
+	b.mu.Unlock()
+	b.render()
+	fmt.Println()
+}
+
 func (b *Bar) finish() {
 	b.mu.Lock()
 	b.status = Green("Success")
@@ -158,6 +180,7 @@ func (b *Bar) render() {
 	status := b.status
 	name := b.name
 	speed := b.speed
+	limit := b.limit
 	b.mu.Unlock()
 
 	percent := float64(current) / float64(b.total) * 100
@@ -181,6 +204,9 @@ func (b *Bar) render() {
 	currentStr := formatBytes(current)
 	totalStr := formatBytes(b.total)
 	speedStr := formatBytes(int64(speed)) + "/s"
+	if limit > 0 {
+		speedStr += " (limit " + formatBytes(limit) + "/s)"
+	}
 
 	// Build output line
 	line := fmt.Sprintf("%s %s %3.0f%% %s %s/%s %s",
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	goWorkFile    = "go.work"
+	goModFile     = "go.mod"
+	goVersionFile = ".go-version"
+)
+
+// ProjectVersion describes a Go version pinned by a file in the project tree
+type ProjectVersion struct {
+	Tag    string // normalized tag, e.g. go1.22.5
+	Source string // file the version was read from
+}
+
+// findProjectVersion walks up from dir looking for go.work, go.mod or
+// .go-version. The nearest go.mod or .go-version wins, except that a go.work
+// anywhere above it takes precedence, mirroring how the go command itself
+// selects the main modules.
+func findProjectVersion(dir string) (*ProjectVersion, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nearest *ProjectVersion
+	for {
+		if Exists(filepath.Join(dir, goWorkFile)) {
+			return readProjectVersion(filepath.Join(dir, goWorkFile))
+		}
+		if nearest == nil {
+			for _, name := range []string{goModFile, goVersionFile} {
+				path := filepath.Join(dir, name)
+				if !Exists(path) {
+					continue
+				}
+				pv, err := readProjectVersion(path)
+				if err != nil {
+					return nil, err
+				}
+				nearest = pv
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	if nearest == nil {
+		return nil, ErrNoProjectVersion()
+	}
+	return nearest, nil
+}
+
+// readProjectVersion extracts the toolchain a single version file asks for
+func readProjectVersion(path string) (*ProjectVersion, error) {
+	var (
+		tag string
+		err error
+	)
+	if filepath.Base(path) == goVersionFile {
+		tag, err = parseGoVersionFile(path)
+	} else {
+		tag, err = parseGoModFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectVersion{Tag: tag, Source: path}, nil
+}
+
+// resolveVersionFile turns the version read from a .go-version file, which
+// may name a series such as 1.22, into the installed version it selects. It
+// is returned as written when nothing installed matches.
+func resolveVersionFile(version string) string {
+	if tag, err := resolveLocal(version); err == nil && inCache(tag) {
+		return tag
+	}
+	return version
+}
+
+// parseGoVersionFile reads the first non-comment line of a .go-version file
+func parseGoVersionFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return normalizeVersionTag(line), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", NewError(fmt.Sprintf("no version found in %s", path))
+}
+
+// parseGoModFile reads the go and toolchain directives of a go.mod or go.work
+// file. The toolchain directive wins over the go directive.
+func parseGoModFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var goVer, toolchain string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			goVer = fields[1]
+		case "toolchain":
+			toolchain = fields[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if toolchain != "" && toolchain != "default" {
+		return toolchainTag(toolchain), nil
+	}
+	if goVer != "" {
+		return goDirectiveTag(goVer), nil
+	}
+	return "", NewError(fmt.Sprintf("no go or toolchain directive found in %s", path))
+}
+
+// toolchainTag strips a custom suffix such as go1.22.5+auto or go1.22.5-custom
+func toolchainTag(name string) string {
+	if i := strings.IndexAny(name, "+-"); i != -1 {
+		name = name[:i]
+	}
+	return normalizeVersionTag(name)
+}
+
+// goDirectiveTag converts a go directive into the release it requires.
+// Since Go 1.21 "go 1.21" names the language version, whose first release
+// is go1.21.0; earlier language versions map to releases like go1.20.
+func goDirectiveTag(ver string) string {
+	ver = strings.TrimPrefix(ver, "go")
+	parts := strings.Split(ver, ".")
+	if len(parts) == 2 && parts[0] == "1" {
+		if minor, err := strconv.Atoi(parts[1]); err == nil && minor >= 21 {
+			ver += ".0"
+		}
+	}
+	return normalizeVersionTag(ver)
+}
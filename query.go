@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version keywords understood by resolveQuery
+const (
+	queryLatest    = "latest"
+	queryStable    = "stable"
+	queryOldStable = "oldstable"
+)
+
+var goVersionRe = regexp.MustCompile(`^(?:go|v)?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:(alpha|beta|rc)(\d*))?$`)
+
+// goVersion is a possibly partial Go version such as 1.22, 1.22.3 or 1.23rc1
+type goVersion struct {
+	Major, Minor, Patch int
+	HasMinor, HasPatch  bool
+	Pre                 string // "alpha", "beta", "rc" or empty for a release
+	PreNum              int    // 0 when the pre-release number was omitted
+}
+
+func parseGoVersion(s string) (goVersion, bool) {
+	m := goVersionRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return goVersion{}, false
+	}
+	var v goVersion
+	v.Major, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		v.Minor, _ = strconv.Atoi(m[2])
+		v.HasMinor = true
+	}
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+		v.HasPatch = true
+	}
+	v.Pre = m[4]
+	if m[5] != "" {
+		v.PreNum, _ = strconv.Atoi(m[5])
+	}
+	return v, true
+}
+
+// String returns the version as a release tag, e.g. go1.22.3
+func (v goVersion) String() string {
+	s := fmt.Sprintf("go%d", v.Major)
+	if v.HasMinor {
+		s += fmt.Sprintf(".%d", v.Minor)
+	}
+	if v.HasPatch {
+		s += fmt.Sprintf(".%d", v.Patch)
+	}
+	if v.Pre != "" {
+		s += v.Pre
+		if v.PreNum > 0 {
+			s += strconv.Itoa(v.PreNum)
+		}
+	}
+	return s
+}
+
+// exact reports whether v names a single release rather than a series
+func (v goVersion) exact() bool {
+	return v.HasPatch || (v.Pre != "" && v.PreNum > 0)
+}
+
+// contains reports whether the concrete version c belongs to the series v
+func (v goVersion) contains(c goVersion) bool {
+	if c.Major != v.Major {
+		return false
+	}
+	if v.HasMinor && c.Minor != v.Minor {
+		return false
+	}
+	if v.HasPatch && c.Patch != v.Patch {
+		return false
+	}
+	if v.PreNum > 0 && (c.Pre != v.Pre || c.PreNum != v.PreNum) {
+		return false
+	}
+	return true
+}
+
+// versionBound is a single comparison such as >=1.20
+type versionBound struct {
+	op string
+	v  goVersion
+}
+
+func (b versionBound) match(c goVersion) bool {
+	cmp := strings.Compare(versionCompare(c.String()), versionCompare(b.v.String()))
+	switch b.op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "~":
+		// ~1.21 and ~1.21.3 stay within the 1.21 series
+		return cmp >= 0 && c.Major == b.v.Major && c.Minor == b.v.Minor
+	default:
+		return b.v.contains(c)
+	}
+}
+
+// versionQuery selects versions by keyword, series or range, e.g. latest,
+// 1.22, ~1.21, ">=1.20 <1.22" or 1.23rc for the 1.23 pre-releases
+type versionQuery struct {
+	raw     string
+	keyword string
+	bounds  []versionBound
+	pre     bool // pre-releases may match
+}
+
+var queryOps = []string{">=", "<=", ">", "<", "=", "~"}
+
+func parseVersionQuery(s string) (*versionQuery, error) {
+	q := &versionQuery{raw: strings.TrimSpace(s)}
+	switch lower := strings.ToLower(q.raw); lower {
+	case "":
+		return nil, ErrTagEmpty()
+	case queryLatest, queryStable, queryOldStable:
+		q.keyword = lower
+		return q, nil
+	}
+
+	terms := strings.FieldsFunc(q.raw, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, term := range terms {
+		op := ""
+		for _, o := range queryOps {
+			if strings.HasPrefix(term, o) {
+				op = o
+				break
+			}
+		}
+		v, ok := parseGoVersion(strings.TrimPrefix(term, op))
+		if !ok {
+			return nil, ErrInvalidVersionQuery(q.raw)
+		}
+		if op == "~" && !v.HasMinor {
+			return nil, ErrInvalidVersionQuery(q.raw)
+		}
+		if v.Pre != "" {
+			q.pre = true
+		}
+		q.bounds = append(q.bounds, versionBound{op: op, v: v})
+	}
+	return q, nil
+}
+
+// exactTag returns the tag when the query names one specific release
+func (q *versionQuery) exactTag() (string, bool) {
+	if len(q.bounds) != 1 {
+		return "", false
+	}
+	b := q.bounds[0]
+	if (b.op != "" && b.op != "=") || !b.v.exact() {
+		return "", false
+	}
+	return b.v.String(), true
+}
+
+// isKeyword reports whether the query is latest, stable or oldstable
+func (q *versionQuery) isKeyword() bool {
+	return q.keyword != ""
+}
+
+// matches reports whether the concrete version tag satisfies every bound
+func (q *versionQuery) matches(tag string) bool {
+	c, ok := parseGoVersion(tag)
+	if !ok || !c.HasMinor {
+		return false
+	}
+	if c.Pre != "" && !q.pre {
+		return false
+	}
+	for _, b := range q.bounds {
+		if !b.match(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve picks the newest release satisfying the query
+func (q *versionQuery) resolve(releases []GoRelease) (string, error) {
+	sorted := make([]GoRelease, len(releases))
+	copy(sorted, releases)
+	sort.Slice(sorted, func(i, j int) bool {
+		return versionCompare(sorted[i].Version) > versionCompare(sorted[j].Version)
+	})
+
+	switch q.keyword {
+	case queryLatest, queryStable:
+		for _, r := range sorted {
+			if r.Stable {
+				return r.Version, nil
+			}
+		}
+	case queryOldStable:
+		// oldstable is the newest release of the series before the current one
+		newest := -1
+		for _, r := range sorted {
+			v, ok := parseGoVersion(r.Version)
+			if !r.Stable || !ok {
+				continue
+			}
+			if newest == -1 {
+				newest = v.Minor
+			} else if v.Minor < newest {
+				return r.Version, nil
+			}
+		}
+	default:
+		for _, r := range sorted {
+			if q.matches(r.Version) {
+				return r.Version, nil
+			}
+		}
+	}
+	return "", ErrNoVersionMatches(q.raw)
+}
+
+// localReleases presents installed versions as releases for resolve
+func localReleases(versions []string) []GoRelease {
+	releases := make([]GoRelease, 0, len(versions))
+	for _, v := range versions {
+		gv, ok := parseGoVersion(v)
+		if !ok {
+			continue
+		}
+		releases = append(releases, GoRelease{Version: v, Stable: gv.Pre == ""})
+	}
+	return releases
+}
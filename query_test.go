@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestResolveQuery(t *testing.T) {
+	releases := []GoRelease{
+		{Version: "go1.19.13", Stable: true},
+		{Version: "go1.20", Stable: true},
+		{Version: "go1.20.14", Stable: true},
+		{Version: "go1.21.0", Stable: true},
+		{Version: "go1.21.13", Stable: true},
+		{Version: "go1.22.0", Stable: true},
+		{Version: "go1.22.5", Stable: true},
+		{Version: "go1.23rc1"},
+		{Version: "go1.23rc2"},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// a series before 1.21 is also the tag of its .0 release, the
+		// newest patch still wins
+		{"1.20", "go1.20.14"},
+		{"go1.20", "go1.20.14"},
+		{"1.22", "go1.22.5"},
+		{"1.22.0", "go1.22.0"},
+		{"~1.21", "go1.21.13"},
+		{"~1.20.3", "go1.20.14"},
+		{">=1.20 <1.22", "go1.21.13"},
+		{"1.23rc", "go1.23rc2"},
+		{"1.23rc1", "go1.23rc1"},
+		{"latest", "go1.22.5"},
+		{"stable", "go1.22.5"},
+		{"oldstable", "go1.21.13"},
+	}
+	paths = &Paths{Home: t.TempDir()} // no aliases
+	a := &app{}
+	for _, tt := range tests {
+		got, err := a.resolveAvailable(tt.query, releases)
+		if err != nil {
+			t.Errorf("resolve(%q): %v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolve(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionQueryInvalid(t *testing.T) {
+	for _, query := range []string{"", "~1", "1.x", ">=abc"} {
+		if _, err := parseVersionQuery(query); err == nil {
+			t.Errorf("parseVersionQuery(%q) succeeded, want an error", query)
+		}
+	}
+}
+
+func TestResolveQueryNoMatch(t *testing.T) {
+	q, err := parseVersionQuery("1.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag, err := q.resolve([]GoRelease{{Version: "go1.22.5", Stable: true}}); err == nil {
+		t.Errorf("resolve(1.30) = %s, want no match", tag)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every download of the process, so
+// the limit applies to the total rather than to each connection
+type rateLimiter struct {
+	rate  float64 // bytes per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+var (
+	limiterOnce   sync.Once
+	sharedLimiter *rateLimiter
+)
+
+// downloadLimiter returns the limiter for cfg.LimitRate, or nil when
+// downloads are not limited
+func downloadLimiter() *rateLimiter {
+	limiterOnce.Do(func() {
+		if cfg.LimitRate > 0 {
+			sharedLimiter = newRateLimiter(cfg.LimitRate)
+		}
+	})
+	return sharedLimiter
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	rate := float64(bytesPerSec)
+	// a twentieth of a second worth of tokens keeps the flow, and the
+	// progress bar, smooth instead of bursting once per second
+	burst := max(rate/20, 1024)
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait takes n tokens, sleeping for as long as the bucket is in debt
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttle wraps r so reads go through the shared limiter, if any
+func throttle(ctx context.Context, r io.Reader) io.Reader {
+	l := downloadLimiter()
+	if l == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, l: l}
+}
+
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *rateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > int(lr.l.burst) {
+		p = p[:int(lr.l.burst)]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if werr := lr.l.wait(lr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
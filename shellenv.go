@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// envVar is an environment variable to export, or to unset when Value is empty
+type envVar struct {
+	Name  string
+	Value string
+}
+
+// detectShell guesses the user's shell from $SHELL
+func detectShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	if sh := filepath.Base(os.Getenv("SHELL")); sh != "" && sh != "." {
+		return sh
+	}
+	return "sh"
+}
+
+// formatEnv renders vars as eval-able code for the given shell, or as a JSON
+// object with null for unset variables
+func formatEnv(shell string, vars []envVar) (string, error) {
+	if shell == "json" {
+		obj := make(map[string]any, len(vars))
+		for _, v := range vars {
+			if v.Value == "" {
+				obj[v.Name] = nil
+			} else {
+				obj[v.Name] = v.Value
+			}
+		}
+		data, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	}
+
+	var b strings.Builder
+	for _, v := range vars {
+		switch shell {
+		case "bash", "zsh", "sh", "dash", "ksh":
+			if v.Value == "" {
+				fmt.Fprintf(&b, "unset %s\n", v.Name)
+			} else {
+				fmt.Fprintf(&b, "export %s=%s\n", v.Name, posixQuote(v.Value))
+			}
+		case "fish":
+			if v.Value == "" {
+				fmt.Fprintf(&b, "set -e %s\n", v.Name)
+				continue
+			}
+			// fish keeps PATH-like variables as lists
+			values := []string{v.Value}
+			if strings.HasSuffix(v.Name, "PATH") {
+				values = filepath.SplitList(v.Value)
+			}
+			for i := range values {
+				values[i] = posixQuote(values[i])
+			}
+			fmt.Fprintf(&b, "set -gx %s %s\n", v.Name, strings.Join(values, " "))
+		case "powershell", "pwsh":
+			if v.Value == "" {
+				fmt.Fprintf(&b, "Remove-Item Env:%s -ErrorAction SilentlyContinue\n", v.Name)
+			} else {
+				fmt.Fprintf(&b, "$env:%s = %s\n", v.Name, powershellQuote(v.Value))
+			}
+		case "nu", "nushell":
+			switch {
+			case v.Value == "":
+				fmt.Fprintf(&b, "hide-env -i %s\n", v.Name)
+			case strings.HasSuffix(v.Name, "PATH"):
+				// nushell keeps PATH as a list
+				values := filepath.SplitList(v.Value)
+				for i := range values {
+					values[i] = nuQuote(values[i])
+				}
+				fmt.Fprintf(&b, "$env.%s = [%s]\n", v.Name, strings.Join(values, " "))
+			default:
+				fmt.Fprintf(&b, "$env.%s = %s\n", v.Name, nuQuote(v.Value))
+			}
+		default:
+			return "", NewError("unsupported shell: " + shell)
+		}
+	}
+	return b.String(), nil
+}
+
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func nuQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// globalEnv returns the variables that follow the global version at
+// paths.Root. paths.Bin goes first so the go/gofmt shims take effect.
+func globalEnv() []envVar {
+	rootBin := filepath.Join(paths.Root, "bin")
+	dirs := []string{paths.Bin, rootBin}
+	for _, dir := range filepath.SplitList(stripCachePath(os.Getenv("PATH"))) {
+		if c := filepath.Clean(dir); c != paths.Bin && c != rootBin {
+			dirs = append(dirs, dir)
+		}
+	}
+	return []envVar{
+		{Name: "SVHOME", Value: paths.Home},
+		{Name: envGoVersion},
+		{Name: "GOROOT", Value: paths.Root},
+		{Name: "PATH", Value: strings.Join(dirs, string(filepath.ListSeparator))},
+	}
+}
+
+// configEnv returns the extra variables from the config file in stable order
+func configEnv() []envVar {
+	names := make([]string, 0, len(cfg.Env))
+	for name := range cfg.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make([]envVar, 0, len(names))
+	for _, name := range names {
+		vars = append(vars, envVar{Name: name, Value: cfg.Env[name]})
+	}
+	return vars
+}
+
+// writeEnvFiles regenerates the scripts sourced by shell profiles. They refer
+// to $HOME rather than an absolute path, like the ones install.sh used to write.
+func writeEnvFiles() ([]string, error) {
+	extra := configEnv()
+
+	var posix, fish, nu, ps strings.Builder
+	posix.WriteString("#!/bin/sh\n# sv shell setup, generated by sv env --write\n")
+	posix.WriteString("export SVHOME=\"$HOME/.sv\"\nexport GOROOT=\"$HOME/.sv/go\"\n")
+	fish.WriteString("# sv shell setup, generated by sv env --write\n")
+	fish.WriteString("set -gx SVHOME \"$HOME/.sv\"\nset -gx GOROOT \"$HOME/.sv/go\"\n")
+	nu.WriteString("# sv shell setup, generated by sv env --write\n")
+	nu.WriteString("$env.SVHOME = ($env.HOME | path join \".sv\")\n$env.GOROOT = ($env.SVHOME | path join \"go\")\n")
+	ps.WriteString("# sv shell setup, generated by sv env --write\n")
+	ps.WriteString("$env:SVHOME = Join-Path $HOME '.sv'\n$env:GOROOT = Join-Path $env:SVHOME 'go'\n")
+
+	for _, v := range extra {
+		fmt.Fprintf(&posix, "export %s=%s\n", v.Name, posixQuote(v.Value))
+		fmt.Fprintf(&fish, "set -gx %s %s\n", v.Name, posixQuote(v.Value))
+		fmt.Fprintf(&nu, "$env.%s = %s\n", v.Name, nuQuote(v.Value))
+		fmt.Fprintf(&ps, "$env:%s = %s\n", v.Name, powershellQuote(v.Value))
+	}
+
+	posix.WriteString(`case ":${PATH}:" in
+    *:"$HOME/.sv/bin:$HOME/.sv/go/bin":*)
+        ;;
+    *)
+        export PATH="$HOME/.sv/bin:$HOME/.sv/go/bin:$PATH"
+        ;;
+esac
+`)
+	fish.WriteString(`if not contains -- "$HOME/.sv/bin" $PATH
+    set -gx PATH "$HOME/.sv/bin" "$HOME/.sv/go/bin" $PATH
+end
+`)
+	nu.WriteString(`$env.PATH = ($env.PATH | split row (char esep) | prepend [($env.SVHOME | path join "bin") ($env.GOROOT | path join "bin")] | uniq)
+`)
+	ps.WriteString(`$svBin = Join-Path $env:SVHOME 'bin'
+if (($env:PATH -split [IO.Path]::PathSeparator) -notcontains $svBin) {
+    $env:PATH = $svBin + [IO.Path]::PathSeparator + (Join-Path $env:GOROOT 'bin') + [IO.Path]::PathSeparator + $env:PATH
+}
+`)
+
+	files := map[string]string{
+		"env":      posix.String(),
+		"env.fish": fish.String(),
+		"env.nu":   nu.String(),
+		"env.ps1":  ps.String(),
+	}
+	var written []string
+	for _, name := range []string{"env", "env.fish", "env.nu", "env.ps1"} {
+		path := filepath.Join(paths.Home, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// sessionEnv returns the variables that select tag for the current shell
+// session only, or restore the global version when tag is empty
+func sessionEnv(tag string) []envVar {
+	path := stripCachePath(os.Getenv("PATH"))
+	if tag == "" {
+		return []envVar{
+			{Name: envGoVersion},
+			{Name: "GOROOT", Value: paths.Root},
+			{Name: "PATH", Value: path},
+		}
+	}
+
+	goroot := filepath.Join(paths.Cache, tag)
+	bin := filepath.Join(goroot, "bin")
+	if path != "" {
+		bin += string(filepath.ListSeparator) + path
+	}
+	return []envVar{
+		{Name: envGoVersion, Value: tag},
+		{Name: "GOROOT", Value: goroot},
+		{Name: "PATH", Value: bin},
+	}
+}
+
+// stripCachePath removes bin directories of installed versions from a PATH
+// value so switching sessions repeatedly does not stack them up
+func stripCachePath(path string) string {
+	var kept []string
+	prefix := paths.Cache + string(os.PathSeparator)
+	for _, dir := range filepath.SplitList(path) {
+		if strings.HasPrefix(filepath.Clean(dir), prefix) {
+			continue
+		}
+		kept = append(kept, dir)
+	}
+	return strings.Join(kept, string(filepath.ListSeparator))
+}
+
+// evalError reports err on stderr, since stdout is meant to be eval'd
+func evalError(err error) error {
+	fmt.Fprintf(os.Stderr, "sv: %v\n", err)
+	return cli.Exit("", 1)
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// envGoVersion overrides the version picked by shims
+const envGoVersion = "SV_GO_VERSION"
+
+// shimNames are the executables sv installs into paths.Bin as shims
+var shimNames = []string{"go", "gofmt"}
+
+// shimName reports whether sv was invoked through one of its shims
+func shimName() (string, bool) {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	for _, s := range shimNames {
+		if name == s {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// runShim execs the real tool of the version selected for the working
+// directory and returns the exit code of the tool
+func runShim(name string, args []string) int {
+	if err := initPaths(); err != nil {
+		fmt.Fprintf(os.Stderr, "sv: %v\n", err)
+		return 1
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sv: %v\n", err)
+		return 1
+	}
+
+	tag, source := resolveVersion(wd)
+	if tag == "" {
+		fmt.Fprintln(os.Stderr, "sv: no Go version is active, run: sv use <version>")
+		return 1
+	}
+
+	goroot := filepath.Join(paths.Cache, tag)
+	bin := filepath.Join(goroot, "bin", name)
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	if !Exists(bin) {
+		fmt.Fprintf(os.Stderr, "sv: %s (set by %s) is not installed, run: sv install %s\n", tag, source, tag)
+		return 1
+	}
+
+	code, err := runCommand(bin, args, goEnv(goroot))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sv: %v\n", err)
+	}
+	return code
+}
+
+// resolveVersion returns the version that applies to dir and where it was
+// set: the SV_GO_VERSION override, then the nearest .go-version file, then
+// the global version that paths.Root points to. SV_GO_VERSION may name an
+// alias.
+func resolveVersion(dir string) (tag, source string) {
+	if v := os.Getenv(envGoVersion); v != "" {
+		return normalizeVersionTag(expandAlias(v)), envGoVersion
+	}
+	if path := findLocalVersionFile(dir); path != "" {
+		if v, err := parseGoVersionFile(path); err == nil {
+			return resolveVersionFile(v), path
+		}
+	}
+	if v := getGlobalVersion(); v != "" {
+		return v, paths.Root
+	}
+	return "", ""
+}
+
+// findLocalVersionFile walks up from dir to the nearest .go-version file
+func findLocalVersionFile(dir string) string {
+	for {
+		path := filepath.Join(dir, goVersionFile)
+		if Exists(path) {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// runCommand runs name with stdio attached and returns its exit code. On
+// Unix the current process is replaced so signals reach the tool directly.
+func runCommand(name string, args []string, env []string) (int, error) {
+	if runtime.GOOS != "windows" {
+		// Exec only returns on failure
+		err := syscall.Exec(name, append([]string{name}, args...), env)
+		return 1, fmt.Errorf("failed to exec %s: %w", name, err)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+// lookPathIn resolves a bare command name in dir first and then in PATH, so
+// "go" picks the requested toolchain rather than a shim
+func lookPathIn(dir, name string) (string, error) {
+	if filepath.Base(name) == name {
+		candidate := filepath.Join(dir, name)
+		if runtime.GOOS == "windows" && filepath.Ext(candidate) == "" {
+			candidate += ".exe"
+		}
+		if Exists(candidate) {
+			return candidate, nil
+		}
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("command not found: %s", name)
+	}
+	return path, nil
+}
+
+// installShims links the shim executables in paths.Bin to the sv binary
+func installShims() error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate sv executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(self); err == nil {
+		self = resolved
+	}
+
+	for _, name := range shimNames {
+		shim := filepath.Join(paths.Bin, name)
+		if runtime.GOOS == "windows" {
+			shim += ".exe"
+		}
+
+		if target, err := os.Readlink(shim); err == nil && target == self {
+			continue
+		}
+		os.Remove(shim)
+
+		// Windows symlinks need extra privileges, so fall back to hard links
+		if runtime.GOOS == "windows" {
+			err = os.Link(self, shim)
+		} else {
+			err = os.Symlink(self, shim)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create %s shim: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// shimsShadowed reports whether paths.Root/bin comes before paths.Bin in PATH,
+// in which case the global go binary is found before the shims
+func shimsShadowed() bool {
+	rootBin := filepath.Join(paths.Root, "bin")
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		switch filepath.Clean(dir) {
+		case paths.Bin:
+			return false
+		case rootBin:
+			return true
+		}
+	}
+	return false
+}
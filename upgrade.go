@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -29,11 +30,14 @@ type Asset struct {
 func NewUpgrade(force bool) *Upgrade {
 	return &Upgrade{
 		force:  force,
-		client: &http.Client{Timeout: cfg.HTTPTimeout},
+		client: newHTTPClient(cfg.HTTPTimeout),
 	}
 }
 
-func (u *Upgrade) checkUpgrade() error {
+func (u *Upgrade) checkUpgrade(ctx context.Context) error {
+	if cfg.Offline {
+		return ErrOffline("sv self upgrade needs the network")
+	}
 	PrintCyan("Checking version...")
 
 	release, err := u.fetchLatestRelease()
@@ -51,7 +55,7 @@ func (u *Upgrade) checkUpgrade() error {
 	}
 
 	PrintBlue(fmt.Sprintf("Upgrading to %s (%s)", release.TagName, asset.Name))
-	return u.downloadAndInstall(asset)
+	return u.downloadAndInstall(ctx, asset)
 }
 
 func (u *Upgrade) fetchLatestRelease() (*Release, error) {
@@ -86,8 +90,13 @@ func (u *Upgrade) findMatchingAsset(assets []Asset) *Asset {
 	return nil
 }
 
-func (u *Upgrade) downloadAndInstall(asset *Asset) error {
-	resp, err := u.client.Get(asset.BrowserDownloadURL)
+func (u *Upgrade) downloadAndInstall(ctx context.Context, asset *Asset) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.BrowserDownloadURL, nil)
+	if err != nil {
+		return err
+	}
+	// no overall timeout, the body may take a while under --limit-rate
+	resp, err := newHTTPClient(0).Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download: %w", err)
 	}
@@ -99,6 +108,7 @@ func (u *Upgrade) downloadAndInstall(asset *Asset) error {
 
 	bar := NewBar(asset.Size)
 	bar.SetName("sv[upgrade]", "cyan")
+	bar.SetLimit(cfg.LimitRate)
 
 	tmpFile := filepath.Join(paths.Bin, ".sv.tmp")
 	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
@@ -106,9 +116,10 @@ func (u *Upgrade) downloadAndInstall(asset *Asset) error {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
 
-	_, err = io.Copy(io.MultiWriter(f, bar), resp.Body)
+	_, err = io.Copy(io.MultiWriter(f, bar), throttle(ctx, resp.Body))
 	f.Close()
 	if err != nil {
+		bar.Stop()
 		os.Remove(tmpFile)
 		return fmt.Errorf("failed to download: %w", err)
 	}
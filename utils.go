@@ -4,11 +4,12 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
-	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -32,14 +33,40 @@ func generateFileName(tag string) string {
 	return fmt.Sprintf("%s.%s-%s%s", tag, runtime.GOOS, runtime.GOARCH, ext)
 }
 
-// retryFunc executes fn with exponential backoff retry
-func retryFunc(fn func() error, maxRetries int) error {
+var archiveNameRe = regexp.MustCompile(`^(go\d+(?:\.\d+)*(?:(?:alpha|beta|rc)\d+)?)\.([a-z0-9]+)-([a-z0-9]+)(\.tar\.gz|\.tgz|\.zip)$`)
+
+// parseArchiveName splits an official archive name such as
+// go1.22.5.linux-amd64.tar.gz into its tag, OS, architecture and extension
+func parseArchiveName(name string) (tag, goos, goarch, ext string, ok bool) {
+	m := archiveNameRe.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", "", "", false
+	}
+	return m[1], m[2], m[3], m[4], true
+}
+
+// archiveExt returns the archive extension understood by Extract
+func archiveExt(name string) string {
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// retryFunc executes fn with exponential backoff retry, giving up as soon
+// as ctx is cancelled
+func retryFunc(ctx context.Context, fn func() error, maxRetries int) error {
 	var lastErr error
 	delay := 500 * time.Millisecond
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if err := fn(); err != nil {
 			lastErr = err
+			if ctx.Err() != nil {
+				return err
+			}
 			if attempt < maxRetries-1 {
 				// Add jitter to prevent thundering herd
 				jitter := time.Duration(rand.Int63n(int64(delay / 2)))
@@ -47,7 +74,11 @@ func retryFunc(fn func() error, maxRetries int) error {
 
 				Warnf("Attempt %d/%d failed: %v, retrying in %v...",
 					attempt+1, maxRetries, err, waitTime)
-				time.Sleep(waitTime)
+				select {
+				case <-time.After(waitTime):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 
 				// Exponential backoff with cap
 				delay *= 2
@@ -63,123 +94,104 @@ func retryFunc(fn func() error, maxRetries int) error {
 	return fmt.Errorf("all %d attempts failed, last error: %w", maxRetries, lastErr)
 }
 
-// Extract extracts an archive to the destination directory
-func Extract(dst, src string) error {
-	PrintCyan("extracting...")
-	switch {
-	case strings.HasSuffix(src, ".tar.gz"), strings.HasSuffix(src, ".tgz"):
-		return unpackTar(dst, src)
-	case strings.HasSuffix(src, ".zip"):
-		return unpackZip(dst, src)
-	default:
-		return fmt.Errorf("unsupported archive format: %s", src)
-	}
-}
-
-func unpackTar(dst, src string) error {
-	file, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	gr, err := gzip.NewReader(file)
-	if err != nil {
-		return err
-	}
-	defer gr.Close()
+// archiveVersion reads the release tag from the go/VERSION file inside an
+// archive without extracting it
+func archiveVersion(src string) (string, error) {
+	const versionFile = "go/VERSION"
 
-	tr := tar.NewReader(gr)
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			return nil
-		}
+	var r io.Reader
+	switch archiveExt(src) {
+	case ".tar.gz", ".tgz":
+		file, err := os.Open(src)
 		if err != nil {
-			return err
-		}
-		if header == nil {
-			continue
+			return "", err
 		}
-
-		target := filepath.Join(dst, header.Name)
-
-		// Security: prevent path traversal attacks (zip slip)
-		if !strings.HasPrefix(filepath.Clean(target), filepath.Clean(dst)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", header.Name)
+		defer file.Close()
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			return "", err
 		}
+		defer gr.Close()
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
+		tr := tar.NewReader(gr)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return "", fmt.Errorf("%s not found in %s", versionFile, src)
 			}
-		case tar.TypeReg:
-			if err := extractFile(target, tr, os.FileMode(header.Mode)); err != nil {
-				return err
+			if err != nil {
+				return "", err
+			}
+			if header.Name == versionFile {
+				r = tr
+				break
 			}
-		case tar.TypeSymlink:
-			// Skip symlinks for security
-			continue
 		}
+	case ".zip":
+		zr, err := zip.OpenReader(src)
+		if err != nil {
+			return "", err
+		}
+		defer zr.Close()
+		rc, err := zr.Open(versionFile)
+		if err != nil {
+			return "", fmt.Errorf("%s not found in %s", versionFile, src)
+		}
+		defer rc.Close()
+		r = rc
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s", src)
 	}
+
+	// The first line holds the tag, later lines hold build metadata
+	data, err := io.ReadAll(io.LimitReader(r, 4096))
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	if line = strings.TrimSpace(line); line == "" {
+		return "", fmt.Errorf("empty %s in %s", versionFile, src)
+	}
+	return line, nil
 }
 
-func unpackZip(dst, src string) error {
-	r, err := zip.OpenReader(src)
+// copyFile copies src to dst through a temporary file so dst is never partial
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
+	defer in.Close()
 
-	if err := os.MkdirAll(dst, 0755); err != nil {
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
 		return err
 	}
-
-	for _, f := range r.File {
-		target := filepath.Join(dst, f.Name)
-
-		// Security: prevent path traversal attacks (zip slip)
-		if !strings.HasPrefix(filepath.Clean(target), filepath.Clean(dst)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", f.Name)
-		}
-
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
-			}
-			continue
-		}
-
-		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-			return err
-		}
-
-		rc, err := f.Open()
-		if err != nil {
-			return err
-		}
-
-		if err := extractFile(target, rc, f.Mode()); err != nil {
-			rc.Close()
-			return err
-		}
-		rc.Close()
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
 	}
-
-	return nil
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
 }
 
-func extractFile(target string, r io.Reader, mode os.FileMode) error {
-	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+// sameFile reports whether a and b refer to the same existing file
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
 	if err != nil {
-		return err
+		return false
 	}
-	_, err = io.Copy(f, r)
-	if closeErr := f.Close(); err == nil {
-		err = closeErr
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
 	}
-	return err
+	return os.SameFile(ai, bi)
 }
 
 // Exists reports whether the named file or directory exists